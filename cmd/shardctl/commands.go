@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/line-lee/toolkit/sharding"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shardView 分表输出
+type shardView struct {
+	Table string    `json:"table"`
+	Type  string    `json:"type"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// paramView 查询参数输出
+type paramView struct {
+	Table    string    `json:"table"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	EndClose bool      `json:"end_close"`
+}
+
+// createView 建表结果输出
+type createView struct {
+	Table   string `json:"table"`
+	Created bool   `json:"created"`
+}
+
+// retainView 过期分表清理结果输出
+type retainView struct {
+	Kept    int               `json:"kept"`
+	Dropped []string          `json:"dropped"`
+	Failed  map[string]string `json:"failed,omitempty"`
+	Plan    *sharding.Plan    `json:"plan,omitempty"`
+}
+
+// runList shardctl list -primary user_logs
+func runList(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	o := newOptions("list", stderr).mysqlFlags()
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	client, err := o.openMysql()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	shards, err := sharding.ListShards(ctx, client, o.db, o.primary)
+	if err != nil {
+		return err
+	}
+	var views = make([]*shardView, 0, len(shards))
+	var rows = [][]string{{"TABLE", "TYPE", "START", "END"}}
+	for _, shard := range shards {
+		views = append(views, &shardView{Table: shard.Table, Type: shard.Type.String(), Start: shard.Start, End: shard.End})
+		rows = append(rows, []string{shard.Table, shard.Type.String(), formatTime(shard.Start), formatTime(shard.End)})
+	}
+	return o.output(stdout, views, rows)
+}
+
+// runParams shardctl params -primary user_logs -type day -start 2025-08-19 -end 2025-08-22
+func runParams(_ context.Context, args []string, stdout, stderr io.Writer) error {
+	o := newOptions("params", stderr).rangeFlags()
+	o.fs.BoolVar(&o.endClose, "end-close", false, "是否包含结束时间")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	params, err := o.params()
+	if err != nil {
+		return err
+	}
+	var views = make([]*paramView, 0, len(params))
+	var rows = [][]string{{"TABLE", "START", "END", "END_CLOSE"}}
+	for _, param := range params {
+		views = append(views, &paramView{Table: param.TableName, Start: param.Start, End: param.End, EndClose: param.IsEndClose})
+		rows = append(rows, []string{param.TableName, formatTime(param.Start), formatTime(param.End), strconv.FormatBool(param.IsEndClose)})
+	}
+	return o.output(stdout, views, rows)
+}
+
+// runPlan shardctl plan -primary user_logs -type day -start 2025-08-19 -end 2025-08-22
+func runPlan(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	o := newOptions("plan", stderr).mysqlFlags().redisFlags().rangeFlags()
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	return o.eachBucket(ctx, func(tableOptions []*sharding.TableOption) error {
+		var plan = new(sharding.Plan)
+		for _, tableOption := range tableOptions {
+			bucketPlan, err := tableOption.Plan(ctx)
+			if err != nil {
+				return err
+			}
+			plan.Merge(bucketPlan)
+		}
+		if o.json {
+			return o.output(stdout, plan, nil)
+		}
+		_, err := plan.WriteTo(stdout)
+		return err
+	})
+}
+
+// runCreate shardctl create -primary user_logs -type day -start 2025-08-19 -end 2025-08-22
+func runCreate(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	o := newOptions("create", stderr).mysqlFlags().redisFlags().rangeFlags()
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	return o.eachBucket(ctx, func(tableOptions []*sharding.TableOption) error {
+		var views = make([]*createView, 0, len(tableOptions))
+		var rows = [][]string{{"TABLE", "CREATED"}}
+		for _, tableOption := range tableOptions {
+			plan, err := tableOption.Plan(ctx)
+			if err != nil {
+				return err
+			}
+			table, err := tableOption.GetTableNameContext(ctx)
+			if err != nil {
+				return err
+			}
+			views = append(views, &createView{Table: table, Created: !plan.Empty()})
+			rows = append(rows, []string{table, strconv.FormatBool(!plan.Empty())})
+		}
+		return o.output(stdout, views, rows)
+	})
+}
+
+// eachBucket 时间范围内每张分表的 TableOption，按时间排序
+func (o *options) eachBucket(ctx context.Context, fn func(tableOptions []*sharding.TableOption) error) error {
+	schedule, err := o.cutovers()
+	if err != nil {
+		return err
+	}
+	params, err := o.params()
+	if err != nil {
+		return err
+	}
+	mysqlClient, err := o.openMysql()
+	if err != nil {
+		return err
+	}
+	defer mysqlClient.Close()
+	redisClient := o.openRedis()
+	defer redisClient.Close()
+	var cache = sharding.NewMemoryCache(0)
+	var tableOptions = make([]*sharding.TableOption, 0, len(params))
+	for _, param := range params {
+		var builder = sharding.TableBuilder().
+			MysqlClient(mysqlClient).
+			RedisClient(redisClient).
+			DBName(o.db).
+			Primary(o.primary).
+			ThisTime(param.Start).
+			Type(param.Type).
+			Cache(cache)
+		if len(schedule) > 0 {
+			// 调整后的新基础表名由调整计划决定
+			builder.Schedule(schedule...)
+		}
+		tableOptions = append(tableOptions, sharding.New(builder))
+	}
+	return fn(tableOptions)
+}
+
+// runDrift shardctl drift -primary user_logs
+func runDrift(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	o := newOptions("drift", stderr).mysqlFlags()
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	client, err := o.openMysql()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	reports, err := sharding.CheckDrift(ctx, client, o.db, o.primary)
+	if err != nil {
+		return err
+	}
+	var rows = [][]string{{"TABLE", "SCOPE", "NAME", "DRIFT", "EXPECT", "ACTUAL"}}
+	for _, report := range reports {
+		for _, scope := range []struct {
+			name   string
+			drifts []*sharding.Drift
+		}{{"column", report.Columns}, {"index", report.Indexes}, {"option", report.Options}} {
+			for _, drift := range scope.drifts {
+				rows = append(rows, []string{report.Table, scope.name, drift.Name, string(drift.Kind), drift.Expect, drift.Actual})
+			}
+		}
+	}
+	return o.output(stdout, reports, rows)
+}
+
+// runRetain shardctl retain -primary user_logs -keep 720h -dry-run
+func runRetain(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	o := newOptions("retain", stderr).mysqlFlags()
+	var before string
+	var keep time.Duration
+	var dryRun bool
+	o.fs.StringVar(&before, "before", "", "保留截止时间，结束时间不晚于该时间的分表会被删除，与 -keep 二选一")
+	o.fs.DurationVar(&keep, "keep", 0, "保留时长，例如 720h，与 -before 二选一")
+	o.fs.BoolVar(&dryRun, "dry-run", false, "只输出删表语句，不执行")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	var cutoff time.Time
+	switch {
+	case before != "" && keep > 0:
+		return fmt.Errorf("-before 和 -keep 只能设置一个")
+	case before != "":
+		var err error
+		if cutoff, err = parseTime("before", before); err != nil {
+			return err
+		}
+	case keep > 0:
+		cutoff = time.Now().Add(-keep)
+	default:
+		return fmt.Errorf("-before 或 -keep 必填")
+	}
+	client, err := o.openMysql()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	builder := sharding.RetainBuilder().MysqlClient(client).DBName(o.db).Primary(o.primary).Before(cutoff)
+	if dryRun {
+		builder.DryRun()
+	}
+	result, err := sharding.Retain(ctx, builder)
+	if result == nil {
+		return err
+	}
+	if dryRun && !o.json {
+		if _, writeErr := result.Plan.WriteTo(stdout); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+	var view = &retainView{Kept: result.Kept, Dropped: result.Dropped, Plan: result.Plan, Failed: make(map[string]string)}
+	var rows = [][]string{{"TABLE", "RESULT"}}
+	for _, table := range result.Dropped {
+		rows = append(rows, []string{table, "dropped"})
+	}
+	var failed = make([]string, 0, len(result.Failed))
+	for table, failErr := range result.Failed {
+		view.Failed[table] = failErr.Error()
+		failed = append(failed, table)
+	}
+	sort.Strings(failed)
+	for _, table := range failed {
+		rows = append(rows, []string{table, view.Failed[table]})
+	}
+	if outputErr := o.output(stdout, view, rows); outputErr != nil {
+		return outputErr
+	}
+	return err
+}
+
+// runGaps shardctl gaps -primary user_logs -type hour -start "2025-08-19 00:00" -end "2025-08-20 00:00"
+func runGaps(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	o := newOptions("gaps", stderr).mysqlFlags().rangeFlags()
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	builder, err := o.paramsBuilder()
+	if err != nil {
+		return err
+	}
+	client, err := o.openMysql()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	report, err := sharding.FindGapsParams(ctx, client, o.db, builder)
+	if err != nil {
+		return err
+	}
+	var rows = [][]string{{"TABLE", "STATUS", "START", "END"}}
+	for _, gap := range report.Missing {
+		rows = append(rows, []string{gap.Table, "missing", formatTime(gap.Start), formatTime(gap.End)})
+	}
+	for _, table := range report.Extra {
+		rows = append(rows, []string{table, "extra", "", ""})
+	}
+	return o.output(stdout, report, rows)
+}
+
+// runStats shardctl stats -primary user_logs -max-rows 10000000 -max-growth 1
+func runStats(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	o := newOptions("stats", stderr).mysqlFlags()
+	var maxRows, maxData, maxIndex int64
+	var maxIndexRatio, maxGrowth float64
+	o.fs.Int64Var(&maxRows, "max-rows", 0, "单张分表估算行数上限，0 不检查")
+	o.fs.Int64Var(&maxData, "max-data", 0, "单张分表数据大小上限，单位字节，0 不检查")
+	o.fs.Int64Var(&maxIndex, "max-index", 0, "单张分表索引大小上限，单位字节，0 不检查")
+	o.fs.Float64Var(&maxIndexRatio, "max-index-ratio", 0, "索引大小与数据大小比值上限，0 不检查")
+	o.fs.Float64Var(&maxGrowth, "max-growth", 0, "相邻分表增长率上限，1 表示增长 100%，0 不检查")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	client, err := o.openMysql()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	report, err := sharding.Stats(ctx, sharding.StatsBuilder().
+		MysqlClient(client).
+		DBName(o.db).
+		Primary(o.primary).
+		MaxRows(maxRows).
+		MaxDataLength(maxData).
+		MaxIndexLength(maxIndex).
+		MaxIndexRatio(maxIndexRatio).
+		MaxGrowth(maxGrowth))
+	if err != nil {
+		return err
+	}
+	var violated = make(map[string][]string)
+	for _, violation := range report.Violations {
+		violated[violation.Table] = append(violated[violation.Table], violation.Metric)
+	}
+	var rows = [][]string{{"TABLE", "ROWS", "DATA", "INDEX", "UPDATED", "ROWS_GROWTH", "DATA_GROWTH", "VIOLATIONS"}}
+	for _, stats := range report.Shards {
+		var updated string
+		if !stats.UpdatedAt.IsZero() {
+			updated = formatTime(stats.UpdatedAt)
+		}
+		rows = append(rows, []string{stats.Table, strconv.FormatInt(stats.Rows, 10), strconv.FormatInt(stats.DataLength, 10),
+			strconv.FormatInt(stats.IndexLength, 10), updated, formatRate(stats.RowsGrowth), formatRate(stats.DataGrowth),
+			strings.Join(violated[stats.Table], ",")})
+	}
+	return o.output(stdout, report, rows)
+}
+
+// formatRate 输出增长率百分比
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', 1, 64) + "%"
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/redis/go-redis/v9"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// timeLayouts 命令行时间格式，按本地时区解析
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02 15", "2006-01-02", "2006-01"}
+
+// options 子命令参数
+type options struct {
+	fs *flag.FlagSet
+	// -h 时输出命令参数说明
+	stderr io.Writer
+
+	// 连接
+	dsn           string
+	db            string
+	redisAddr     string
+	redisPassword string
+
+	// 分表
+	primary  string
+	t        string
+	schedule string
+	tiers    string
+	start    string
+	end      string
+	endClose bool
+
+	// 输出 json
+	json bool
+}
+
+// newOptions 子命令参数，默认带 -primary 和 -json，-h 时参数说明输出到 stderr
+func newOptions(name string, stderr io.Writer) *options {
+	var o = &options{fs: flag.NewFlagSet("shardctl "+name, flag.ContinueOnError), stderr: stderr}
+	o.fs.StringVar(&o.primary, "primary", "", "基础表名（必填）")
+	o.fs.BoolVar(&o.json, "json", false, "输出 json")
+	return o
+}
+
+// mysqlFlags mysql 连接参数
+func (o *options) mysqlFlags() *options {
+	o.fs.StringVar(&o.dsn, "dsn", "", "mysql 连接，默认取环境变量 SHARDCTL_DSN")
+	o.fs.StringVar(&o.db, "db", "", "库名，默认取环境变量 SHARDCTL_DB，未设置时取 dsn 中的库名")
+	return o
+}
+
+// redisFlags redis 连接参数
+func (o *options) redisFlags() *options {
+	o.fs.StringVar(&o.redisAddr, "redis-addr", "", "redis 地址，默认取环境变量 SHARDCTL_REDIS_ADDR，未设置时为 127.0.0.1:6379")
+	o.fs.StringVar(&o.redisPassword, "redis-password", "", "redis 密码，默认取环境变量 SHARDCTL_REDIS_PASSWORD")
+	return o
+}
+
+// rangeFlags 分表类型和时间范围
+func (o *options) rangeFlags() *options {
+	o.fs.StringVar(&o.t, "type", "", "分表类型：hour、day、month、year，与 -schedule、-tiers 三选一")
+	o.fs.StringVar(&o.schedule, "schedule", "", "分表粒度调整计划，逗号分隔的 类型[@生效时间][=新基础表名]，例如 month,day@2025-09-01")
+	o.fs.StringVar(&o.tiers, "tiers", "", "分层分表，逗号分隔的 类型[:保留时长]，例如 hour:168h,day:2160h,month")
+	o.fs.StringVar(&o.start, "start", "", "开始时间，例如 2025-08-19 17:00:00（必填）")
+	o.fs.StringVar(&o.end, "end", "", "结束时间，不包含（必填）")
+	return o
+}
+
+// parse 解析参数并校验必填项
+func (o *options) parse(args []string) error {
+	o.fs.SetOutput(io.Discard)
+	if err := o.fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			o.fs.SetOutput(o.stderr)
+			o.fs.Usage()
+		}
+		return err
+	}
+	if o.fs.NArg() > 0 {
+		return fmt.Errorf("多余的参数：%s", strings.Join(o.fs.Args(), " "))
+	}
+	if strings.TrimSpace(o.primary) == "" {
+		return fmt.Errorf("-primary 必填")
+	}
+	// 未通过 flag 设置的连接参数从环境变量读取，不作为 flag 默认值，避免 -h 输出密码
+	o.dsn = orEnv(o.dsn, "SHARDCTL_DSN")
+	o.db = orEnv(o.db, "SHARDCTL_DB")
+	o.redisAddr = orEnv(o.redisAddr, "SHARDCTL_REDIS_ADDR")
+	if o.redisAddr == "" {
+		o.redisAddr = "127.0.0.1:6379"
+	}
+	o.redisPassword = orEnv(o.redisPassword, "SHARDCTL_REDIS_PASSWORD")
+	if o.fs.Lookup("dsn") != nil {
+		if strings.TrimSpace(o.dsn) == "" {
+			return fmt.Errorf("-dsn 或环境变量 SHARDCTL_DSN 必填")
+		}
+		if o.db == "" {
+			config, err := mysql.ParseDSN(o.dsn)
+			if err != nil {
+				return fmt.Errorf("-dsn 格式错误：%w", err)
+			}
+			o.db = config.DBName
+		}
+		if o.db == "" {
+			return fmt.Errorf("-db 必填，或在 dsn 中指定库名")
+		}
+	}
+	return nil
+}
+
+// openMysql 打开 mysql 连接
+func (o *options) openMysql() (*sql.DB, error) {
+	client, err := sql.Open("mysql", o.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mysql 连接失败：%w", err)
+	}
+	return client, nil
+}
+
+// openRedis 打开 redis 连接
+func (o *options) openRedis() *redis.Client {
+	return redis.NewClient(&redis.Options{Addr: o.redisAddr, Password: o.redisPassword})
+}
+
+// shardType 解析 -type
+func (o *options) shardType() (sharding.Type, error) {
+	return parseType("type", o.t)
+}
+
+// cutovers 解析 -schedule，未设置时为空
+func (o *options) cutovers() ([]sharding.Cutover, error) {
+	var schedule = make([]sharding.Cutover, 0)
+	if strings.TrimSpace(o.schedule) == "" {
+		return schedule, nil
+	}
+	for _, entry := range strings.Split(o.schedule, ",") {
+		var cutover sharding.Cutover
+		entry, cutover.Primary, _ = strings.Cut(strings.TrimSpace(entry), "=")
+		name, from, ok := strings.Cut(entry, "@")
+		t, err := parseType("schedule", name)
+		if err != nil {
+			return nil, err
+		}
+		cutover.Type = t
+		if ok {
+			if cutover.From, err = parseTime("schedule", from); err != nil {
+				return nil, err
+			}
+		}
+		schedule = append(schedule, cutover)
+	}
+	return schedule, nil
+}
+
+// tierList 解析 -tiers，未设置时为空
+func (o *options) tierList() ([]sharding.Tier, error) {
+	var tiers = make([]sharding.Tier, 0)
+	if strings.TrimSpace(o.tiers) == "" {
+		return tiers, nil
+	}
+	for _, entry := range strings.Split(o.tiers, ",") {
+		name, age, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		t, err := parseType("tiers", name)
+		if err != nil {
+			return nil, err
+		}
+		var tier = sharding.Tier{Type: t}
+		if ok {
+			if tier.Age, err = time.ParseDuration(age); err != nil {
+				return nil, fmt.Errorf("-tiers 保留时长格式不识别：%q，例如 168h", age)
+			}
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+func parseType(name, value string) (sharding.Type, error) {
+	for _, t := range []sharding.Type{sharding.Hour, sharding.Day, sharding.Month, sharding.Year} {
+		if strings.EqualFold(strings.TrimSpace(value), t.String()) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("-%s 不识别：%q，可选 hour、day、month、year", name, value)
+}
+
+// timeRange 解析 -start、-end
+func (o *options) timeRange() (time.Time, time.Time, error) {
+	start, err := parseTime("start", o.start)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := parseTime("end", o.end)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
+}
+
+// paramsBuilder 按 -type（或 -schedule、-tiers）、-start、-end 生成拆分参数
+func (o *options) paramsBuilder() (*sharding.ParamsOptionsBuilder, error) {
+	start, end, err := o.timeRange()
+	if err != nil {
+		return nil, err
+	}
+	var builder = sharding.ParamsBuilder().Primary(o.primary).Start(start).End(end).IsEndClose(o.endClose)
+	var layouts = 0
+	for _, value := range []string{o.t, o.schedule, o.tiers} {
+		if strings.TrimSpace(value) != "" {
+			layouts++
+		}
+	}
+	if layouts > 1 {
+		return nil, fmt.Errorf("-type、-schedule、-tiers 只能设置一个")
+	}
+	schedule, err := o.cutovers()
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := o.tierList()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(schedule) > 0:
+		builder.Schedule(schedule...)
+	case len(tiers) > 0:
+		builder.Tiers(tiers...)
+	default:
+		t, err := o.shardType()
+		if err != nil {
+			return nil, err
+		}
+		builder.Type(t)
+	}
+	return builder, nil
+}
+
+// params 按 -type（或 -schedule、-tiers）、-start、-end 拆分分表
+func (o *options) params() ([]*sharding.ParamsResult, error) {
+	builder, err := o.paramsBuilder()
+	if err != nil {
+		return nil, err
+	}
+	return sharding.Params(builder)
+}
+
+func parseTime(name, value string) (time.Time, error) {
+	if strings.TrimSpace(value) == "" {
+		return time.Time{}, fmt.Errorf("-%s 必填", name)
+	}
+	for _, layout := range timeLayouts {
+		if tm, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return tm, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("-%s 时间格式不识别：%q，例如 2025-08-19 17:00:00", name, value)
+}
+
+// output 按 -json 输出 json 或表格，rows 第一行为表头
+func (o *options) output(w io.Writer, value any, rows [][]string) error {
+	if o.json {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// formatTime 输出时间
+func formatTime(tm time.Time) string {
+	return tm.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,92 @@
+// shardctl 分表运维命令行工具，基于 sharding 包
+//
+// 用法：
+//
+//	shardctl <command> [flags]
+//
+// 连接参数可以通过 flag 或环境变量传入：
+//
+//	-dsn             SHARDCTL_DSN             mysql 连接，例如 root:root@tcp(127.0.0.1:3306)/my_database
+//	-db              SHARDCTL_DB              库名，默认取 dsn 中的库名
+//	-redis-addr      SHARDCTL_REDIS_ADDR      redis 地址，create 建表加锁使用
+//	-redis-password  SHARDCTL_REDIS_PASSWORD  redis 密码
+//
+// 所有命令都支持 -json 输出 json。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+// command 子命令
+type command struct {
+	// 说明
+	usage string
+	// 执行，-h 时命令参数说明输出到 stderr
+	run func(ctx context.Context, args []string, stdout, stderr io.Writer) error
+}
+
+var commands = map[string]*command{
+	"list":   {usage: "列出基础表已存在的分表", run: runList},
+	"plan":   {usage: "输出时间范围内缺少的分表的建表语句，不执行", run: runPlan},
+	"create": {usage: "创建时间范围内缺少的分表", run: runCreate},
+	"params": {usage: "输出时间范围拆分出的分表查询参数", run: runParams},
+	"drift":  {usage: "比较分表与基础表的结构差异", run: runDrift},
+	"retain": {usage: "删除超出保留期的分表，-dry-run 只输出删表语句", run: runRetain},
+	"gaps":   {usage: "检查时间范围内缺少的分表和不符合命名规则的表", run: runGaps},
+	"stats":  {usage: "分表行数、数据和索引大小、增长率，按阈值检查", run: runStats},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "shardctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(stderr)
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(stderr)
+		return fmt.Errorf("未知命令 %q", args[0])
+	}
+	return cmd.run(ctx, args[1:], stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "用法：shardctl <command> [flags]")
+	fmt.Fprintln(w, "\n命令：")
+	var names = make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(w, "\n使用 shardctl <command> -h 查看命令参数")
+}
+
+// orEnv value 为空时读取环境变量 key
+func orEnv(value, key string) string {
+	if strings.TrimSpace(value) != "" {
+		return value
+	}
+	return os.Getenv(key)
+}
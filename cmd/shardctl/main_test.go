@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+// TestRun 测试命令分发和不需要连接数据库的 params 命令
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("命令分发", func(t *testing.T) {
+		var stderr bytes.Buffer
+		require.ErrorIs(t, run(ctx, nil, io.Discard, &stderr), flag.ErrHelp)
+		require.Contains(t, stderr.String(), "retain")
+		require.ErrorContains(t, run(ctx, []string{"unknown"}, io.Discard, io.Discard), "未知命令")
+
+		// 命令参数说明输出到传入的 stderr
+		stderr.Reset()
+		require.ErrorIs(t, run(ctx, []string{"gaps", "-h"}, io.Discard, &stderr), flag.ErrHelp)
+		require.Contains(t, stderr.String(), "shardctl gaps")
+		require.Contains(t, stderr.String(), "-schedule")
+	})
+
+	t.Run("参数校验", func(t *testing.T) {
+		t.Setenv("SHARDCTL_DSN", "")
+		require.ErrorContains(t, run(ctx, []string{"list"}, io.Discard, io.Discard), "-primary 必填")
+		require.ErrorContains(t, run(ctx, []string{"list", "-primary", "logs"}, io.Discard, io.Discard), "SHARDCTL_DSN")
+		require.ErrorContains(t, run(ctx, []string{"list", "-primary", "logs", "-dsn", "root:root@tcp(127.0.0.1:3306)/"}, io.Discard, io.Discard), "-db 必填")
+		require.ErrorContains(t, run(ctx, []string{"params", "-primary", "logs", "-type", "week", "-start", "2025-08-19", "-end", "2025-08-20"}, io.Discard, io.Discard), "-type 不识别")
+		require.ErrorContains(t, run(ctx, []string{"params", "-primary", "logs", "-type", "day", "-start", "19/08/2025", "-end", "2025-08-20"}, io.Discard, io.Discard), "-start 时间格式不识别")
+		require.ErrorContains(t, run(ctx, []string{"retain", "-primary", "logs", "-dsn", "root:root@tcp(127.0.0.1:3306)/test"}, io.Discard, io.Discard), "-before 或 -keep 必填")
+	})
+
+	t.Run("params 表格", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run(ctx, []string{"params", "-primary", "logs", "-type", "day", "-start", "2025-08-19 17:00", "-end", "2025-08-21"}, &stdout, io.Discard)
+		require.NoError(t, err)
+		require.Equal(t, "TABLE          START                END                  END_CLOSE\n"+
+			"logs_20250819  2025-08-19 17:00:00  2025-08-20 00:00:00  false\n"+
+			"logs_20250820  2025-08-20 00:00:00  2025-08-21 00:00:00  false\n", stdout.String())
+	})
+
+	t.Run("params 粒度调整和分层分表", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run(ctx, []string{"params", "-primary", "logs", "-schedule", "month,day@2025-09-15=logs_v2", "-start", "2025-09-10", "-end", "2025-09-17"}, &stdout, io.Discard)
+		require.NoError(t, err)
+		require.Equal(t, "TABLE             START                END                  END_CLOSE\n"+
+			"logs_202509       2025-09-10 00:00:00  2025-09-15 00:00:00  false\n"+
+			"logs_v2_20250915  2025-09-15 00:00:00  2025-09-16 00:00:00  false\n"+
+			"logs_v2_20250916  2025-09-16 00:00:00  2025-09-17 00:00:00  false\n", stdout.String())
+
+		require.NoError(t, run(ctx, []string{"params", "-primary", "logs", "-tiers", "hour:1h,day", "-start", "2025-08-19", "-end", "2025-08-21", "-json"}, io.Discard, io.Discard))
+		require.ErrorContains(t, run(ctx, []string{"params", "-primary", "logs", "-type", "day", "-tiers", "hour:1h,day", "-start", "2025-08-19", "-end", "2025-08-21"}, io.Discard, io.Discard), "只能设置一个")
+		require.ErrorContains(t, run(ctx, []string{"params", "-primary", "logs", "-schedule", "week", "-start", "2025-08-19", "-end", "2025-08-21"}, io.Discard, io.Discard), "-schedule 不识别")
+		require.ErrorContains(t, run(ctx, []string{"params", "-primary", "logs", "-tiers", "hour:7d,day", "-start", "2025-08-19", "-end", "2025-08-21"}, io.Discard, io.Discard), "-tiers 保留时长格式不识别")
+	})
+
+	t.Run("params json", func(t *testing.T) {
+		var stdout bytes.Buffer
+		err := run(ctx, []string{"params", "-primary", "logs", "-type", "hour", "-start", "2025-08-19 17:30", "-end", "2025-08-19 19:00", "-end-close", "-json"}, &stdout, io.Discard)
+		require.NoError(t, err)
+		var views []*paramView
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &views))
+		require.Len(t, views, 3)
+		require.Equal(t, "logs_2025081917", views[0].Table)
+		require.Equal(t, "logs_2025081919", views[2].Table)
+		require.True(t, views[2].EndClose)
+	})
+}
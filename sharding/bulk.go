@@ -0,0 +1,536 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/line-lee/toolkit/beankit"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 默认每批处理的行数
+const defaultBulkBatchSize = 1000
+
+// BulkAction 批量操作对分表执行的动作
+type BulkAction string
+
+const (
+	BulkTruncate BulkAction = "truncate" // 分表整体在范围内，清空
+	BulkDrop     BulkAction = "drop"     // 分表整体在范围内，删表
+	BulkDelete   BulkAction = "delete"   // 分批 DELETE
+	BulkUpdate   BulkAction = "update"   // 分批 UPDATE
+)
+
+// BulkProgress 批量操作进度，每批执行后回调一次
+type BulkProgress struct {
+	// 分表名
+	Table string
+	// 执行的动作
+	Action BulkAction
+	// 已执行的批数
+	Batches int
+	// 该分表累计影响的行数，TRUNCATE、DROP 时为 -1
+	Rows int64
+	// 该分表是否处理完成
+	Done bool
+}
+
+// BulkResult 批量删除、更新结果
+type BulkResult struct {
+	// 清空的分表
+	Truncated []string
+	// 删除的分表
+	Dropped []string
+	// 分批 DELETE、UPDATE 影响的行数，key 为表名
+	Rows map[string]int64
+	// DryRun 时将要执行的语句
+	Plan *Plan
+}
+
+// Total 分批 DELETE、UPDATE 影响的总行数
+func (br *BulkResult) Total() int64 {
+	var total int64
+	for _, rows := range br.Rows {
+		total += rows
+	}
+	return total
+}
+
+// DeleteRange 删除基础表 primary 在 [Start, End) 内的数据：分表整体在范围内且没有 Where 条件时 TRUNCATE（设置 Drop 时 DROP），
+// 部分在范围内的分表按 TimeColumn 分批 DELETE ... LIMIT BatchSize，只处理已存在的分表，遇到错误时停止并返回已完成的结果
+func DeleteRange(ctx context.Context, builder *BulkOptionsBuilder) (*BulkResult, error) {
+	option, shards, err := builder.prepare(ctx, "sharding.DeleteRange")
+	if err != nil {
+		return nil, err
+	}
+	var result = option.newResult()
+	for _, shard := range shards {
+		var full = option.covers(shard)
+		if full && option.where == "" {
+			if err = option.clear(ctx, shard.Table, result); err != nil {
+				return result, err
+			}
+			continue
+		}
+		where, args := option.condition(full)
+		var deleteSql = fmt.Sprintf("DELETE FROM %s.%s WHERE %s LIMIT %d", quote(option.db), quote(shard.Table), where, option.batchSize)
+		if option.dryRun {
+			result.Plan.add(shard.Table, deleteSql, fmt.Sprintf("分批删除，每批 %d 行，参数：%v", option.batchSize, args))
+			continue
+		}
+		var progress = &BulkProgress{Table: shard.Table, Action: BulkDelete}
+		for !progress.Done {
+			res, err := option.mysqlClient.ExecContext(ctx, deleteSql, args...)
+			if err != nil {
+				return result, fmt.Errorf("sharding.DeleteRange，%s：%w", shard.Table, err)
+			}
+			affected, _ := res.RowsAffected()
+			progress.Batches++
+			progress.Rows += affected
+			progress.Done = affected < int64(option.batchSize)
+			result.Rows[shard.Table] = progress.Rows
+			option.report(ctx, progress)
+		}
+	}
+	return result, nil
+}
+
+// UpdateRange 更新基础表 primary 在 [Start, End) 内满足 Where 的数据，set 为 SET 子句，例如 "`status` = ?"，
+// 按 KeyColumn 分段，每段 BatchSize 行，避免长事务和 SET 改变匹配条件导致的重复更新
+func UpdateRange(ctx context.Context, builder *BulkOptionsBuilder, set string, args ...any) (*BulkResult, error) {
+	if strings.TrimSpace(set) == "" {
+		return nil, invalidOption("Set", "sharding.UpdateRange，set 必填")
+	}
+	option, shards, err := builder.prepare(ctx, "sharding.UpdateRange")
+	if err != nil {
+		return nil, err
+	}
+	var result = option.newResult()
+	var key = quote(option.keyColumn)
+	for _, shard := range shards {
+		where, whereArgs := option.condition(option.covers(shard))
+		var table = fmt.Sprintf("%s.%s", quote(option.db), quote(shard.Table))
+		var updateSql = func(first bool) string {
+			var chunk = where
+			if !first {
+				chunk += fmt.Sprintf(" AND %s > ?", key)
+			}
+			return fmt.Sprintf("UPDATE %s SET %s WHERE %s AND %s <= ?", table, set, chunk, key)
+		}
+		if option.dryRun {
+			result.Plan.add(shard.Table, updateSql(false), fmt.Sprintf("按 %s 分段更新，每段 %d 行，SET 参数：%v，条件参数：%v", option.keyColumn, option.batchSize, args, whereArgs))
+			continue
+		}
+		var progress = &BulkProgress{Table: shard.Table, Action: BulkUpdate}
+		var lower any
+		for !progress.Done {
+			var first = lower == nil
+			var chunkArgs = append([]any{}, whereArgs...)
+			if !first {
+				chunkArgs = append(chunkArgs, lower)
+			}
+			upper, err := chunkUpper(ctx, option.mysqlClient, table, where, whereArgs, key, lower, option.batchSize)
+			if err != nil {
+				return result, fmt.Errorf("sharding.UpdateRange，%s：%w", shard.Table, err)
+			}
+			if upper == nil {
+				progress.Done = true
+				option.report(ctx, progress)
+				break
+			}
+			var updateArgs = append(append(append([]any{}, args...), chunkArgs...), upper)
+			res, err := option.mysqlClient.ExecContext(ctx, updateSql(first), updateArgs...)
+			if err != nil {
+				return result, fmt.Errorf("sharding.UpdateRange，%s：%w", shard.Table, err)
+			}
+			affected, _ := res.RowsAffected()
+			progress.Batches++
+			progress.Rows += affected
+			result.Rows[shard.Table] = progress.Rows
+			lower = upper
+			option.report(ctx, progress)
+		}
+	}
+	return result, nil
+}
+
+// sqlConn *sql.DB、*sql.Conn 共有的查询方法，Compact 锁表后需要在同一个连接上执行
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// chunkUpper 按 key 分段时下一段的结束 key：大于 lower 的前 size 行中最大的 key，lower 为 nil 时为第一段，没有数据时返回 nil。
+// key 按驱动给出的列类型扫描（例如 BIGINT 为 sql.NullInt64），原样作为下一段的参数，
+// 避免按字符串传参时 MySQL 以 DOUBLE 比较，超过 2^53 的雪花 ID 分段边界不准
+func chunkUpper(ctx context.Context, client sqlConn, table, where string, args []any, key string, lower any, size int) (any, error) {
+	var chunkArgs = append([]any{}, args...)
+	if lower != nil {
+		where += fmt.Sprintf(" AND %s > ?", key)
+		chunkArgs = append(chunkArgs, lower)
+	}
+	var query = fmt.Sprintf("SELECT MAX(%s) FROM (SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT %d) AS chunk", key, key, table, where, key, size)
+	rows, err := client.QueryContext(ctx, query, chunkArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	var scanType = types[0].ScanType()
+	if scanType == nil || scanType == reflect.TypeOf(sql.RawBytes{}) {
+		// RawBytes 在 rows 关闭后失效
+		scanType = reflect.TypeOf([]byte{})
+	}
+	var upper = reflect.New(scanType)
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	if err = rows.Scan(upper.Interface()); err != nil {
+		return nil, err
+	}
+	// MAX 没有数据时为 NULL
+	var value = upper.Elem()
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Interface:
+		if value.IsNil() {
+			return nil, rows.Err()
+		}
+	}
+	if valuer, ok := value.Interface().(driver.Valuer); ok {
+		if v, err := valuer.Value(); err != nil || v == nil {
+			return nil, err
+		}
+	}
+	return value.Interface(), rows.Err()
+}
+
+// prepare 校验参数，列出与时间范围有交集的分表
+func (bb *BulkOptionsBuilder) prepare(ctx context.Context, name string) (*BulkOption, []*Shard, error) {
+	option := new(BulkOption)
+	for _, opf := range bb.funcs {
+		opf(option)
+	}
+	if option.mysqlClient == nil {
+		return nil, nil, invalidOption("MysqlClient", name+"，option MysqlClient 必填")
+	}
+	if beankit.IsStringBlank(option.db) {
+		return nil, nil, invalidOption("DBName", name+"，option DBName 必填")
+	}
+	if beankit.IsStringBlank(option.primary) {
+		return nil, nil, invalidOption("Primary", name+"，option Primary 必填")
+	}
+	if err := validateUnit(option.unit); err != nil {
+		return nil, nil, err
+	}
+	if option.start.IsZero() {
+		return nil, nil, invalidOption("Start", name+"，option Start 必填")
+	}
+	if option.end.IsZero() || !option.end.After(option.start) {
+		return nil, nil, invalidOption("End", name+"，option End 必须晚于 Start")
+	}
+	if option.batchSize <= 0 {
+		option.batchSize = defaultBulkBatchSize
+	}
+	if option.keyColumn == "" {
+		option.keyColumn = "id"
+	}
+	shards, err := ListShards(ctx, option.mysqlClient, option.db, option.primary)
+	if err != nil {
+		return nil, nil, err
+	}
+	var matched = make([]*Shard, 0, len(shards))
+	for _, shard := range shards {
+		if shard.Start.Before(option.end) && shard.End.After(option.start) {
+			matched = append(matched, shard)
+		}
+	}
+	// 部分在范围内的分表需要按时间列过滤
+	for _, shard := range matched {
+		if !option.covers(shard) && beankit.IsStringBlank(option.timeColumn) {
+			return nil, nil, invalidOption("TimeColumn", fmt.Sprintf("%s，分表 %s 部分在范围内，option TimeColumn 必填", name, shard.Table))
+		}
+	}
+	return option, matched, nil
+}
+
+// covers 分表是否整体在范围内
+func (bo *BulkOption) covers(shard *Shard) bool {
+	return !shard.Start.Before(bo.start) && !shard.End.After(bo.end)
+}
+
+// condition WHERE 条件，分表整体在范围内时不需要时间条件
+func (bo *BulkOption) condition(full bool) (string, []any) {
+	var conditions = make([]string, 0, 2)
+	var args = make([]any, 0, len(bo.args)+2)
+	if !full {
+		where, rangeArgs := rangeCondition(bo.timeColumn, bo.unit, bo.start, bo.end, false)
+		conditions = append(conditions, where)
+		args = append(args, rangeArgs...)
+	}
+	if bo.where != "" {
+		conditions = append(conditions, "("+bo.where+")")
+		args = append(args, bo.args...)
+	}
+	if len(conditions) == 0 {
+		return "1 = 1", args
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// clear 清空或删除整体在范围内的分表
+func (bo *BulkOption) clear(ctx context.Context, table string, result *BulkResult) error {
+	var action, statement = BulkTruncate, fmt.Sprintf("TRUNCATE TABLE %s.%s", quote(bo.db), quote(table))
+	if bo.drop {
+		action, statement = BulkDrop, dropShardSql(bo.db, table)
+	}
+	if bo.dryRun {
+		result.Plan.add(table, statement, "分表整体在删除范围内")
+		return nil
+	}
+	if _, err := bo.mysqlClient.ExecContext(ctx, statement); err != nil {
+		bo.getLogger().ErrorContext(ctx, "sharding.DeleteRange，分表清理失败", slog.String("table", table), slog.String("sql", statement), slog.Any("err", err))
+		return &DDLError{SQL: statement, Cause: err}
+	}
+	if bo.drop {
+		bo.getCache().Invalidate(ctx, CacheKey(bo.db, table))
+		// 登记表可以在其他连接或库中，只标记状态
+		if bo.registry != nil {
+			if err := bo.registry.MarkDropped(ctx, bo.primary, table); err != nil {
+				return err
+			}
+		}
+		result.Dropped = append(result.Dropped, table)
+	} else {
+		result.Truncated = append(result.Truncated, table)
+	}
+	bo.report(ctx, &BulkProgress{Table: table, Action: action, Batches: 1, Rows: -1, Done: true})
+	return nil
+}
+
+func (bo *BulkOption) newResult() *BulkResult {
+	var result = &BulkResult{Truncated: make([]string, 0), Dropped: make([]string, 0), Rows: make(map[string]int64)}
+	if bo.dryRun {
+		result.Plan = new(Plan)
+	}
+	return result
+}
+
+// report 记录日志并回调进度，批次之间按 Pause 暂停
+func (bo *BulkOption) report(ctx context.Context, progress *BulkProgress) {
+	bo.getLogger().DebugContext(ctx, "sharding.Bulk，批次完成", slog.String("table", progress.Table),
+		slog.String("action", string(progress.Action)), slog.Int("batches", progress.Batches), slog.Int64("rows", progress.Rows))
+	if bo.progress != nil {
+		bo.progress(*progress)
+	}
+	if !progress.Done && bo.pause > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(bo.pause):
+		}
+	}
+}
+
+// BulkOption 批量删除、更新参数，由 BulkBuilder 传入
+type BulkOption struct {
+	// 数据库连接
+	mysqlClient *sql.DB
+	// 库名
+	db string
+	// 基础表名
+	primary string
+	// 时间范围，左闭右开
+	start time.Time
+	end   time.Time
+	// 时间列，部分在范围内的分表按该列过滤
+	timeColumn string
+	// 时间列为 unix 时间戳时的单位
+	unit UnixUnit
+	// 附加条件
+	where string
+	args  []any
+	// 每批处理的行数，默认 1000
+	batchSize int
+	// UpdateRange 分段使用的列，默认 id，需要有索引
+	keyColumn string
+	// 整体在范围内的分表 DROP，默认 TRUNCATE
+	drop bool
+	// 分表登记表，DROP 成功后标记为已删除
+	registry *Registry
+	// 批次之间暂停，减轻主从延迟
+	pause time.Duration
+	// 进度回调
+	progress func(BulkProgress)
+	// 只计算语句，不执行
+	dryRun bool
+	// 分表存在性缓存，删表后失效，默认 DefaultCache()
+	cache Cache
+	// 日志，默认 slog.Default()
+	logger *slog.Logger
+}
+
+func (bo *BulkOption) getCache() Cache {
+	if bo.cache != nil {
+		return bo.cache
+	}
+	return DefaultCache()
+}
+
+// getLogger 日志默认使用 slog.Default()，统一带上库名、基础表名
+func (bo *BulkOption) getLogger() *slog.Logger {
+	var logger = bo.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With(slog.String("db", bo.db), slog.String("primary", bo.primary))
+}
+
+type BulkOptionsBuilder struct {
+	funcs []BulkOptionFunc
+}
+
+func BulkBuilder() *BulkOptionsBuilder {
+	return &BulkOptionsBuilder{}
+}
+
+type BulkOptionFunc func(*BulkOption)
+
+func (bb *BulkOptionsBuilder) MysqlClient(mysqlClient *sql.DB) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.mysqlClient = mysqlClient
+	})
+	return bb
+}
+
+func (bb *BulkOptionsBuilder) DBName(dbName string) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.db = dbName
+	})
+	return bb
+}
+
+func (bb *BulkOptionsBuilder) Primary(primary string) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.primary = primary
+	})
+	return bb
+}
+
+// Start 范围开始时间（包含）
+func (bb *BulkOptionsBuilder) Start(start time.Time) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.start = start
+	})
+	return bb
+}
+
+// End 范围结束时间（不包含）
+func (bb *BulkOptionsBuilder) End(end time.Time) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.end = end
+	})
+	return bb
+}
+
+// TimeColumn 时间列，分表部分在范围内时按该列过滤
+func (bb *BulkOptionsBuilder) TimeColumn(column string) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.timeColumn = column
+	})
+	return bb
+}
+
+// Unix 时间列为 unix 时间戳，按时间列过滤时参数使用时间戳
+func (bb *BulkOptionsBuilder) Unix(unit UnixUnit) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.unit = unit
+	})
+	return bb
+}
+
+// Where 附加条件，例如 Where("`user_id` = ?", userID)，设置后整体在范围内的分表也分批执行
+func (bb *BulkOptionsBuilder) Where(where string, args ...any) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.where, opt.args = strings.TrimSpace(where), args
+	})
+	return bb
+}
+
+// BatchSize 每批处理的行数，默认 1000
+func (bb *BulkOptionsBuilder) BatchSize(size int) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.batchSize = size
+	})
+	return bb
+}
+
+// KeyColumn UpdateRange 分段使用的列，默认 id，需要有索引且唯一
+func (bb *BulkOptionsBuilder) KeyColumn(column string) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.keyColumn = column
+	})
+	return bb
+}
+
+// Drop DeleteRange 对整体在范围内的分表使用 DROP，默认 TRUNCATE 保留空表
+func (bb *BulkOptionsBuilder) Drop() *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.drop = true
+	})
+	return bb
+}
+
+// Registry 分表登记表，DROP 仍通过 MysqlClient、DBName 执行，成功后在登记表中标记为已删除
+func (bb *BulkOptionsBuilder) Registry(registry *Registry) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.registry = registry
+	})
+	return bb
+}
+
+// Pause 批次之间暂停，减轻主从延迟
+func (bb *BulkOptionsBuilder) Pause(pause time.Duration) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.pause = pause
+	})
+	return bb
+}
+
+// Progress 进度回调，每批执行后调用
+func (bb *BulkOptionsBuilder) Progress(fn func(BulkProgress)) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.progress = fn
+	})
+	return bb
+}
+
+// DryRun 只计算语句，写入 BulkResult.Plan，不执行
+func (bb *BulkOptionsBuilder) DryRun() *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.dryRun = true
+	})
+	return bb
+}
+
+// Cache 分表存在性缓存，Drop 删表后失效，与 TableBuilder().Cache() 使用同一个缓存，多实例时使用 RedisCache 广播
+func (bb *BulkOptionsBuilder) Cache(c Cache) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.cache = c
+	})
+	return bb
+}
+
+// Logger 设置日志，默认 slog.Default()
+func (bb *BulkOptionsBuilder) Logger(logger *slog.Logger) *BulkOptionsBuilder {
+	bb.funcs = append(bb.funcs, func(opt *BulkOption) {
+		opt.logger = logger
+	})
+	return bb
+}
@@ -0,0 +1,187 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache 分表存在性缓存，key 由 CacheKey 生成
+type Cache interface {
+	// Exists 分表是否已确认存在
+	Exists(ctx context.Context, key string) bool
+	// Store 记录分表已存在
+	Store(ctx context.Context, key string)
+	// Invalidate 删除缓存，分表被删除后调用
+	Invalidate(ctx context.Context, key string)
+}
+
+// CacheKey 分表缓存 key
+func CacheKey(db, table string) string {
+	return fmt.Sprintf("expect_%s_%s", db, table)
+}
+
+// 默认缓存，进程内永不过期
+var (
+	defaultCacheMu sync.RWMutex
+	defaultCache   Cache = NewMemoryCache(0)
+)
+
+// SetDefaultCache 设置默认缓存，TableBuilder 未设置 Cache 时使用
+func SetDefaultCache(c Cache) {
+	defaultCacheMu.Lock()
+	defer defaultCacheMu.Unlock()
+	defaultCache = c
+}
+
+// DefaultCache 当前默认缓存
+func DefaultCache() Cache {
+	defaultCacheMu.RLock()
+	defer defaultCacheMu.RUnlock()
+	return defaultCache
+}
+
+// Invalidate 删除默认缓存中库 db 分表 table 的存在记录
+func Invalidate(ctx context.Context, db, table string) {
+	DefaultCache().Invalidate(ctx, CacheKey(db, table))
+}
+
+// MemoryCache 进程内缓存
+type MemoryCache struct {
+	ttl time.Duration
+	// key -> 过期时间，零值表示不过期
+	entries sync.Map
+}
+
+// NewMemoryCache 进程内缓存，ttl<=0 时不过期
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl}
+}
+
+func (mc *MemoryCache) Exists(_ context.Context, key string) bool {
+	value, ok := mc.entries.Load(key)
+	if !ok {
+		return false
+	}
+	if expire := value.(time.Time); !expire.IsZero() && time.Now().After(expire) {
+		mc.entries.CompareAndDelete(key, value)
+		return false
+	}
+	return true
+}
+
+func (mc *MemoryCache) Store(_ context.Context, key string) {
+	var expire time.Time
+	if mc.ttl > 0 {
+		expire = time.Now().Add(mc.ttl)
+	}
+	mc.entries.Store(key, expire)
+}
+
+func (mc *MemoryCache) Invalidate(_ context.Context, key string) {
+	mc.entries.Delete(key)
+}
+
+// redisCacheChannel 失效广播频道
+const redisCacheChannel = "SHARDING_CACHE_INVALIDATE"
+
+// RedisCache 两级缓存：进程内缓存 + redis 共享缓存，失效时通过 redis pub/sub 广播给所有实例
+type RedisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+	local  *MemoryCache
+	pubsub *redis.PubSub
+	done   chan struct{}
+	logger atomic.Pointer[slog.Logger]
+}
+
+// NewRedisCache 两级缓存，ttl 同时作用于进程内和 redis，ttl<=0 时不过期，只依赖失效广播
+// 使用完毕调用 Close 停止订阅
+func NewRedisCache(client *redis.Client, ttl time.Duration) *RedisCache {
+	rc := &RedisCache{
+		client: client,
+		ttl:    ttl,
+		local:  NewMemoryCache(ttl),
+		pubsub: client.Subscribe(context.Background(), redisCacheChannel),
+		done:   make(chan struct{}),
+	}
+	// 等待订阅确认，避免刚创建时错过失效广播
+	if _, err := rc.pubsub.Receive(context.Background()); err != nil {
+		rc.getLogger().Error("sharding.RedisCache，失效广播订阅失败", slog.Any("err", err))
+	}
+	go rc.subscribe()
+	return rc
+}
+
+func (rc *RedisCache) subscribe() {
+	defer close(rc.done)
+	for message := range rc.pubsub.Channel() {
+		rc.local.Invalidate(context.Background(), message.Payload)
+		rc.getLogger().Debug("sharding.RedisCache，收到失效广播", slog.String("key", message.Payload))
+	}
+}
+
+func (rc *RedisCache) Exists(ctx context.Context, key string) bool {
+	if rc.local.Exists(ctx, key) {
+		return true
+	}
+	count, err := rc.client.Exists(ctx, rc.redisKey(key)).Result()
+	if err != nil {
+		rc.getLogger().WarnContext(ctx, "sharding.RedisCache，缓存查询失败", slog.String("key", key), slog.Any("err", err))
+		return false
+	}
+	if count == 0 {
+		return false
+	}
+	rc.local.Store(ctx, key)
+	return true
+}
+
+func (rc *RedisCache) Store(ctx context.Context, key string) {
+	rc.local.Store(ctx, key)
+	var ttl = rc.ttl
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := rc.client.Set(ctx, rc.redisKey(key), 1, ttl).Err(); err != nil {
+		rc.getLogger().WarnContext(ctx, "sharding.RedisCache，缓存写入失败", slog.String("key", key), slog.Any("err", err))
+	}
+}
+
+func (rc *RedisCache) Invalidate(ctx context.Context, key string) {
+	rc.local.Invalidate(ctx, key)
+	if err := rc.client.Del(ctx, rc.redisKey(key)).Err(); err != nil {
+		rc.getLogger().WarnContext(ctx, "sharding.RedisCache，缓存删除失败", slog.String("key", key), slog.Any("err", err))
+	}
+	if err := rc.client.Publish(ctx, redisCacheChannel, key).Err(); err != nil {
+		rc.getLogger().WarnContext(ctx, "sharding.RedisCache，失效广播失败", slog.String("key", key), slog.Any("err", err))
+	}
+}
+
+// SetLogger 设置日志，默认 slog.Default()
+func (rc *RedisCache) SetLogger(logger *slog.Logger) *RedisCache {
+	rc.logger.Store(logger)
+	return rc
+}
+
+func (rc *RedisCache) getLogger() *slog.Logger {
+	if logger := rc.logger.Load(); logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Close 停止订阅失效广播
+func (rc *RedisCache) Close() error {
+	err := rc.pubsub.Close()
+	<-rc.done
+	return err
+}
+
+func (rc *RedisCache) redisKey(key string) string {
+	return "SHARDING_CACHE_" + key
+}
@@ -0,0 +1,212 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReadPolicy 从库选择策略
+type ReadPolicy int
+
+const (
+	RoundRobin ReadPolicy = 1 // 轮询
+	LeastLag   ReadPolicy = 2 // 复制延迟最小的从库
+)
+
+// 默认复制延迟采样间隔
+const defaultLagInterval = time.Second
+
+// 复制延迟采样超时，采样不受调用方 ctx 取消的影响
+const lagProbeTimeout = time.Second
+
+// LagFunc 查询从库的复制延迟
+type LagFunc func(ctx context.Context, client *sql.DB) (time.Duration, error)
+
+// replica 从库及最近一次复制延迟采样
+type replica struct {
+	client *sql.DB
+	mu     sync.Mutex
+	lag    time.Duration
+	err    error
+	at     time.Time
+}
+
+// Cluster 读写分离，建表、写入使用主库，历史分表查询使用从库
+type Cluster struct {
+	writer   *sql.DB
+	replicas []*replica
+	policy   ReadPolicy
+	// 结束时间在最近 recent 内的分表查询主库，避免复制延迟读不到刚写入的数据
+	recent      time.Duration
+	lagFunc     LagFunc
+	lagInterval time.Duration
+	next        atomic.Uint64
+}
+
+// NewCluster 读写分离，writer 为主库，未添加从库时读写都使用主库
+func NewCluster(writer *sql.DB) *Cluster {
+	return &Cluster{writer: writer, policy: RoundRobin, lagFunc: ReplicaLag, lagInterval: defaultLagInterval}
+}
+
+// Readers 添加从库
+func (c *Cluster) Readers(readers ...*sql.DB) *Cluster {
+	for _, reader := range readers {
+		c.replicas = append(c.replicas, &replica{client: reader})
+	}
+	return c
+}
+
+// Policy 从库选择策略，默认 RoundRobin
+func (c *Cluster) Policy(policy ReadPolicy) *Cluster {
+	c.policy = policy
+	return c
+}
+
+// ForceWriter 结束时间在最近 recent 内的分表查询主库，例如按天分表、ForceWriter(time.Hour) 时，
+// 今天的分表和凌晨一小时内昨天的分表查询主库
+func (c *Cluster) ForceWriter(recent time.Duration) *Cluster {
+	c.recent = recent
+	return c
+}
+
+// Lag 复制延迟查询方法，LeastLag 策略使用，默认 ReplicaLag
+func (c *Cluster) Lag(lagFunc LagFunc) *Cluster {
+	c.lagFunc = lagFunc
+	return c
+}
+
+// LagInterval 复制延迟采样间隔，默认 1 秒，间隔内复用上次的采样结果
+func (c *Cluster) LagInterval(interval time.Duration) *Cluster {
+	c.lagInterval = interval
+	return c
+}
+
+// Writer 主库
+func (c *Cluster) Writer() *sql.DB {
+	return c.writer
+}
+
+// Reader 按策略选择一个从库，没有从库或 LeastLag 策略下所有从库延迟查询失败时返回主库
+func (c *Cluster) Reader(ctx context.Context) *sql.DB {
+	if len(c.replicas) == 0 {
+		return c.writer
+	}
+	if c.policy == LeastLag {
+		return c.leastLag(ctx)
+	}
+	var index = (c.next.Add(1) - 1) % uint64(len(c.replicas))
+	return c.replicas[index].client
+}
+
+// Client 查询分表 param 使用的连接，最近的分表使用主库，其余按策略选择从库
+func (c *Cluster) Client(ctx context.Context, param *ParamsResult) *sql.DB {
+	if c.recent > 0 && !param.End.IsZero() && param.End.After(time.Now().Add(-c.recent)) {
+		return c.writer
+	}
+	return c.Reader(ctx)
+}
+
+// QueryContext 在分表 param 对应的连接上执行查询
+func (c *Cluster) QueryContext(ctx context.Context, param *ParamsResult, query string, args ...any) (*sql.Rows, error) {
+	return c.Client(ctx, param).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext 在分表 param 对应的连接上执行单行查询
+func (c *Cluster) QueryRowContext(ctx context.Context, param *ParamsResult, query string, args ...any) *sql.Row {
+	return c.Client(ctx, param).QueryRowContext(ctx, query, args...)
+}
+
+// ExecContext 在主库执行写入
+func (c *Cluster) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.writer.ExecContext(ctx, query, args...)
+}
+
+// leastLag 复制延迟最小的从库，延迟相同时取靠前的
+func (c *Cluster) leastLag(ctx context.Context) *sql.DB {
+	var best *replica
+	var bestLag time.Duration
+	for _, r := range c.replicas {
+		lag, err := c.sample(ctx, r)
+		if err != nil {
+			continue
+		}
+		if best == nil || lag < bestLag {
+			best, bestLag = r, lag
+		}
+	}
+	if best == nil {
+		return c.writer
+	}
+	return best.client
+}
+
+// sample 从库复制延迟，采样间隔内复用上次结果。采样使用独立的超时，
+// 避免调用方 ctx 取消导致的失败被缓存，使采样间隔内的查询都改用主库
+func (c *Cluster) sample(ctx context.Context, r *replica) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.at.IsZero() && time.Since(r.at) < c.lagInterval {
+		return r.lag, r.err
+	}
+	probeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lagProbeTimeout)
+	defer cancel()
+	r.lag, r.err = c.lagFunc(probeCtx, r.client)
+	r.at = time.Now()
+	return r.lag, r.err
+}
+
+// ReplicaLag 通过 SHOW REPLICA STATUS 查询复制延迟，mysql 8.0.22 以下使用 SHOW SLAVE STATUS，
+// 不是从库时延迟为 0，复制中断（Seconds_Behind_Source 为 NULL）时返回错误
+func ReplicaLag(ctx context.Context, client *sql.DB) (time.Duration, error) {
+	lag, err := replicaLag(ctx, client, "SHOW REPLICA STATUS", "Seconds_Behind_Source")
+	if err != nil {
+		var fallback error
+		if lag, fallback = replicaLag(ctx, client, "SHOW SLAVE STATUS", "Seconds_Behind_Master"); fallback == nil {
+			return lag, nil
+		}
+	}
+	return lag, err
+}
+
+func replicaLag(ctx context.Context, client *sql.DB, query, column string) (time.Duration, error) {
+	rows, err := client.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	var values = make([]sql.RawBytes, len(columns))
+	var dest = make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, name := range columns {
+		if name != column {
+			continue
+		}
+		if values[i] == nil {
+			return 0, errors.New("sharding.ReplicaLag，复制已中断")
+		}
+		seconds, err := strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("sharding.ReplicaLag，%s 解析失败：%w", column, err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, fmt.Errorf("sharding.ReplicaLag，缺少 %s 列", column)
+}
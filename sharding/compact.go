@@ -0,0 +1,491 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/line-lee/toolkit/beankit"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// 分层合并默认提前复制的时长
+const defaultCompactAhead = time.Hour
+
+// ErrChecksumMismatch 合并后目标分表与源分表的行数或校验和不一致，源分表不会被删除
+var ErrChecksumMismatch = errors.New("sharding.Compact，行数或校验和不一致")
+
+// CompactResult 分表合并结果
+type CompactResult struct {
+	// 写入数据的目标分表
+	Targets []string
+	// 合并完成后删除的源分表
+	Dropped []string
+	// 每张目标分表复制的行数，key 为表名
+	Rows map[string]int64
+	// DryRun 时将要执行的语句
+	Plan *Plan
+}
+
+// Compact 把细粒度分表合并到粗粒度分表，例如按小时分表合并为按天分表：
+// 对结束时间不晚于 Before 的粗粒度分表，建表后按 KeyColumn 分批复制所有细粒度源分表，
+// 逐张比对源分表与目标分表中相同 key 的行数和校验和，全部一致后删除源分表。复制跳过目标分表中已有的 key，不删除目标分表中的数据，
+// 中断后重新执行时继续复制，源分表删除后迟到的写入重新建出的源分表在下次执行时补充合并。
+// 删除前对源分表和目标分表 LOCK TABLES，锁内补充复制、重新校验后删除，需要 LOCK TABLES 权限；
+// 删除后写入源分表返回 1146，调用方需要通过 TableOption.Do 写入（或遇到 1146 时 Invalidate 后重新获取分表名），
+// 由其重新建出源分表，并定期执行 Compact 合并这些迟到的数据。
+// 设置 Tiers 时按相邻两层依次合并，分界与 ParamsBuilder().Tiers() 相同：目标分表在分界到达前 Ahead 时提前复制、校验，
+// 源分表保留到 Params 按分界改查目标分表后的下一次执行才删除，因此执行间隔不能超过 Ahead，否则分界到达时目标分表尚未复制
+func Compact(ctx context.Context, builder *CompactOptionsBuilder) (*CompactResult, error) {
+	option := new(CompactOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if option.table == nil {
+		return nil, invalidOption("Table", "sharding.Compact，option Table 必填")
+	}
+	option.base = new(TableOption)
+	for _, opf := range option.table.funcs {
+		opf(option.base)
+	}
+	if option.base.err != nil {
+		return nil, option.base.err
+	}
+	if option.base.mysqlClient == nil {
+		return nil, invalidOption("MysqlClient", "sharding.Compact，option Table 中 MysqlClient 必填")
+	}
+	if beankit.IsStringBlank(option.base.db) {
+		return nil, invalidOption("DBName", "sharding.Compact，option Table 中 DBName 必填")
+	}
+	if beankit.IsStringBlank(option.base.primary) {
+		return nil, invalidOption("Primary", "sharding.Compact，option Table 中 Primary 必填")
+	}
+	if option.base.placement != nil || option.base.keyStrategy != nil || len(option.base.schedule) > 0 {
+		return nil, invalidOption("Table", "sharding.Compact，只支持单库按时间分表，不支持 Placement、Key、Schedule")
+	}
+	if err := validateUnit(option.base.unit); err != nil {
+		return nil, err
+	}
+	if beankit.IsStringBlank(option.timeColumn) {
+		return nil, invalidOption("TimeColumn", "sharding.Compact，option TimeColumn 必填，用于校验")
+	}
+	if option.batchSize <= 0 {
+		option.batchSize = defaultBulkBatchSize
+	}
+	if option.keyColumn == "" {
+		option.keyColumn = "id"
+	}
+	var steps = make([]*compactStep, 0)
+	if len(option.tiers) > 0 {
+		if err := validateTiers(option.tiers); err != nil {
+			return nil, err
+		}
+		if option.now.IsZero() {
+			option.now = time.Now()
+		}
+		if option.ahead <= 0 {
+			option.ahead = defaultCompactAhead
+		}
+		var boundaries = tierBoundaries(option.tiers, option.now)
+		var copyBoundaries = tierBoundaries(option.tiers, option.now.Add(option.ahead))
+		for i, boundary := range boundaries {
+			steps = append(steps, &compactStep{from: option.tiers[i].Type, to: option.tiers[i+1].Type, before: boundary, copyBefore: copyBoundaries[i]})
+		}
+	} else {
+		if option.from.layout() == "" || option.to.layout() == "" {
+			return nil, fmt.Errorf("sharding.Compact，from %d to %d：%w", option.from, option.to, ErrUnknownType)
+		}
+		if option.to <= option.from {
+			return nil, invalidOption("To", fmt.Sprintf("sharding.Compact，目标分表类型 %s 需要比 %s 粗", option.to, option.from))
+		}
+		if option.before.IsZero() {
+			return nil, invalidOption("Before", "sharding.Compact，option Before 必填")
+		}
+		steps = append(steps, &compactStep{from: option.from, to: option.to, before: option.before, copyBefore: option.before})
+	}
+	var result = &CompactResult{Targets: make([]string, 0), Dropped: make([]string, 0), Rows: make(map[string]int64)}
+	if option.dryRun {
+		result.Plan = new(Plan)
+	}
+	for _, step := range steps {
+		if err := option.compact(ctx, step, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// compactStep 一次合并：类型为 from 的分表合并到 to，复制结束时间不晚于 copyBefore 的目标分表，
+// 只删除结束时间不晚于 before 的目标分表的源分表
+type compactStep struct {
+	from       Type
+	to         Type
+	before     time.Time
+	copyBefore time.Time
+}
+
+// compact 执行一次合并，按目标分表分组
+func (co *CompactOption) compact(ctx context.Context, step *compactStep, result *CompactResult) error {
+	shards, err := ListShards(ctx, co.base.mysqlClient, co.base.db, co.base.primary)
+	if err != nil {
+		return err
+	}
+	var groups = make(map[time.Time][]*Shard)
+	var order = make([]time.Time, 0)
+	for _, shard := range shards {
+		if shard.Type != step.from {
+			continue
+		}
+		var start, end = step.to.Bucket(shard.Start)
+		if end.After(step.copyBefore) {
+			continue
+		}
+		if _, ok := groups[start]; !ok {
+			order = append(order, start)
+		}
+		groups[start] = append(groups[start], shard)
+	}
+	for _, start := range order {
+		var _, end = step.to.Bucket(start)
+		if err = co.merge(ctx, step.to, start, groups[start], !end.After(step.before), result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge 把 sources 合并到 start 所在的目标分表，drop 为 false 时只复制、校验，保留源分表
+func (co *CompactOption) merge(ctx context.Context, t Type, start time.Time, sources []*Shard, drop bool, result *CompactResult) error {
+	var funcs = append(append([]TableOptionFunc{}, co.table.funcs...), func(opt *TableOption) {
+		opt.thisTime, opt.t = start, t
+	})
+	var target = New(&TableOptionsBuilder{funcs: funcs})
+	if target.err != nil {
+		return target.err
+	}
+	var db = target.db
+	var logger = co.getLogger().With(slog.String("table", target.expect))
+	if co.dryRun {
+		plan, err := target.Plan(ctx)
+		if err != nil {
+			return err
+		}
+		result.Plan.Merge(plan)
+		for _, source := range sources {
+			columns, err := co.columns(ctx, source.Table)
+			if err != nil {
+				return err
+			}
+			result.Plan.add(target.expect, copySql(db, source.Table, target.expect, columns, ""), fmt.Sprintf("合并 %s，按 %s 每批 %d 行", source.Table, co.keyColumn, co.batchSize))
+			if drop {
+				result.Plan.add(source.Table, dropShardSql(db, source.Table), fmt.Sprintf("已合并到 %s，锁定源分表和目标分表，补充合并、校验一致后删除", target.expect))
+			}
+		}
+		return nil
+	}
+	if _, err := target.GetTableNameContext(ctx); err != nil {
+		return err
+	}
+	result.Targets = append(result.Targets, target.expect)
+	for _, source := range sources {
+		rows, err := co.copy(ctx, co.base.mysqlClient, db, source, target.expect)
+		if err != nil {
+			logger.ErrorContext(ctx, "sharding.Compact，分表合并失败", slog.String("source", source.Table), slog.Any("err", err))
+			return fmt.Errorf("sharding.Compact，%s 合并到 %s：%w", source.Table, target.expect, err)
+		}
+		result.Rows[target.expect] += rows
+		logger.InfoContext(ctx, "sharding.Compact，分表已合并", slog.String("source", source.Table), slog.Int64("rows", rows))
+	}
+	if !drop {
+		// 提前复制，Params 仍查询源分表
+		return nil
+	}
+	for _, source := range sources {
+		rows, err := co.seal(ctx, db, source, target.expect)
+		result.Rows[target.expect] += rows
+		if err != nil {
+			logger.ErrorContext(ctx, "sharding.Compact，源分表删除失败", slog.String("source", source.Table), slog.Any("err", err))
+			return fmt.Errorf("sharding.Compact，%s 合并到 %s：%w", source.Table, target.expect, err)
+		}
+		result.Dropped = append(result.Dropped, source.Table)
+	}
+	return nil
+}
+
+// seal 锁定源分表和目标分表（LOCK TABLES），补充复制后写入源分表的数据并重新校验，一致后在锁内删除源分表，
+// 校验到删除之间源分表不会有新的写入；删除后写入源分表返回 1146，通过 Do 写入时重新建出源分表，下次执行时合并，
+// 返回锁内补充的行数
+func (co *CompactOption) seal(ctx context.Context, db string, source *Shard, target string) (int64, error) {
+	conn, err := co.base.mysqlClient.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	var lockSql = fmt.Sprintf("LOCK TABLES %s.%s WRITE, %s.%s WRITE", quote(db), quote(source.Table), quote(db), quote(target))
+	if _, err = conn.ExecContext(ctx, lockSql); err != nil {
+		return 0, &DDLError{SQL: lockSql, Cause: err}
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "UNLOCK TABLES"); err != nil {
+			// 解锁失败的连接不能放回连接池
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+	rows, err := co.copy(ctx, conn, db, source, target)
+	if err != nil {
+		return rows, err
+	}
+	return rows, co.drop(ctx, conn, db, source.Table)
+}
+
+// copy 把源分表复制到目标分表并校验，返回本次插入的行数，已复制完成时返回 0。
+// 目标分表中已有的 key 跳过，不删除目标分表中的数据：上次中断时继续复制，源分表删除后迟到的写入重新建出的源分表只补充新行
+func (co *CompactOption) copy(ctx context.Context, conn sqlConn, db string, source *Shard, target string) (int64, error) {
+	columns, err := co.columns(ctx, source.Table)
+	if err != nil {
+		return 0, err
+	}
+	expectCount, expectSum, err := co.checksum(ctx, conn, db, source.Table, columns, "1 = 1", nil)
+	if err != nil {
+		return 0, err
+	}
+	// 只统计目标分表中 key 属于源分表的数据，目标分表中已合并的其他数据不影响校验
+	var rangeWhere, rangeArgs = rangeCondition(co.timeColumn, co.base.unit, source.Start, source.End, false)
+	var key = quote(co.keyColumn)
+	var table = fmt.Sprintf("%s.%s", quote(db), quote(source.Table))
+	var targetWhere = fmt.Sprintf("%s AND %s IN (SELECT %s FROM %s)", rangeWhere, key, key, table)
+	count, sum, err := co.checksum(ctx, conn, db, target, columns, targetWhere, rangeArgs)
+	if err != nil {
+		return 0, err
+	}
+	if count == expectCount && sum == expectSum {
+		return 0, nil
+	}
+	var lower any
+	var rows int64
+	for {
+		upper, err := chunkUpper(ctx, conn, table, "1 = 1", nil, key, lower, co.batchSize)
+		if err != nil {
+			return rows, err
+		}
+		if upper == nil {
+			break
+		}
+		var where = fmt.Sprintf("%s <= ?", key)
+		var args = []any{upper}
+		if lower != nil {
+			where = fmt.Sprintf("%s > ? AND %s <= ?", key, key)
+			args = []any{lower, upper}
+		}
+		res, err := conn.ExecContext(ctx, copySql(db, source.Table, target, columns, where), args...)
+		if err != nil {
+			return rows, err
+		}
+		affected, _ := res.RowsAffected()
+		rows += affected
+		lower = upper
+	}
+	count, sum, err = co.checksum(ctx, conn, db, target, columns, targetWhere, rangeArgs)
+	if err != nil {
+		return rows, err
+	}
+	if count != expectCount || sum != expectSum {
+		return rows, fmt.Errorf("%w：源 %d 行 %s，目标 %d 行 %s", ErrChecksumMismatch, expectCount, expectSum, count, sum)
+	}
+	return rows, nil
+}
+
+// columns 源分表的列，按列顺序
+func (co *CompactOption) columns(ctx context.Context, table string) ([]string, error) {
+	const columnSql = "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+	var columns = make([]string, 0)
+	err := queryEach(ctx, co.base.mysqlClient, columnSql, []any{co.base.db, table}, func(rows *sql.Rows) error {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return err
+		}
+		columns = append(columns, column)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sharding.Compact，%s 列信息查询失败：%w", table, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("sharding.Compact，%s 不存在", table)
+	}
+	return columns, nil
+}
+
+// checksum 行数和所有列的 CRC32 之和，NULL 与空字符串区分
+func (co *CompactOption) checksum(ctx context.Context, conn sqlConn, db, table string, columns []string, where string, args []any) (int64, string, error) {
+	var values = make([]string, len(columns))
+	for i, column := range columns {
+		values[i] = fmt.Sprintf("IFNULL(CAST(%s AS CHAR), '<null>')", quote(column))
+	}
+	var query = fmt.Sprintf("SELECT COUNT(*), CAST(IFNULL(SUM(CRC32(CONCAT_WS('#', %s))), 0) AS CHAR) FROM %s.%s WHERE %s",
+		strings.Join(values, ", "), quote(db), quote(table), where)
+	var count int64
+	var sum string
+	err := conn.QueryRowContext(ctx, query, args...).Scan(&count, &sum)
+	return count, sum, err
+}
+
+// drop 在锁表的连接上删除已合并的源分表，设置 Registry 时再标记为已删除，登记表可以在其他连接或库中
+func (co *CompactOption) drop(ctx context.Context, conn sqlConn, db, table string) error {
+	var dropSql = dropShardSql(db, table)
+	if _, err := conn.ExecContext(ctx, dropSql); err != nil {
+		return &DDLError{SQL: dropSql, Cause: err}
+	}
+	co.base.getCache().Invalidate(ctx, CacheKey(db, table))
+	if co.base.registry != nil {
+		return co.base.registry.MarkDropped(ctx, co.base.primary, table)
+	}
+	return nil
+}
+
+// copySql 复制语句，目标分表中已存在的 key 跳过，where 为空时复制整张表
+func copySql(db, source, target string, columns []string, where string) string {
+	var names = make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quote(column)
+	}
+	var list = strings.Join(names, ",")
+	var copySql = fmt.Sprintf("INSERT IGNORE INTO %s.%s (%s) SELECT %s FROM %s.%s", quote(db), quote(target), list, list, quote(db), quote(source))
+	if where != "" {
+		copySql += " WHERE " + where
+	}
+	return copySql
+}
+
+// CompactOption 分表合并参数，由 CompactBuilder 传入
+type CompactOption struct {
+	// 分表配置，用于创建目标分表，不需要 ThisTime、Type
+	table *TableOptionsBuilder
+	base  *TableOption
+	// 源分表类型和目标分表类型
+	from Type
+	to   Type
+	// 只合并结束时间不晚于 before 的目标分表
+	before time.Time
+	// 分层合并，now 为计算分层的当前时间，ahead 为提前复制的时长，默认 1 小时
+	tiers []Tier
+	now   time.Time
+	ahead time.Duration
+	// 时间列，校验目标分表中属于源分表的数据
+	timeColumn string
+	// 分批复制使用的列，默认 id，需要有索引且在合并后的分表中唯一，已有的 key 复制时跳过
+	keyColumn string
+	// 每批复制的行数，默认 1000
+	batchSize int
+	// 只计算语句，不执行
+	dryRun bool
+}
+
+// getLogger 日志默认使用 TableBuilder 的 Logger
+func (co *CompactOption) getLogger() *slog.Logger {
+	return co.base.getLogger()
+}
+
+type CompactOptionsBuilder struct {
+	funcs []CompactOptionFunc
+}
+
+func CompactBuilder() *CompactOptionsBuilder {
+	return &CompactOptionsBuilder{}
+}
+
+type CompactOptionFunc func(*CompactOption)
+
+// Table 分表配置，与 TableBuilder 相同，目标分表按其中的 Schema、DDL、Registry 等创建，
+// 源分表通过其中的 MysqlClient、DBName 删除，设置 Registry 时再标记为已删除
+func (cb *CompactOptionsBuilder) Table(table *TableOptionsBuilder) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.table = table
+	})
+	return cb
+}
+
+// From 源分表类型，例如 Hour
+func (cb *CompactOptionsBuilder) From(t Type) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.from = t
+	})
+	return cb
+}
+
+// To 目标分表类型，例如 Day
+func (cb *CompactOptionsBuilder) To(t Type) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.to = t
+	})
+	return cb
+}
+
+// Before 只合并结束时间不晚于 before 的目标分表，例如保留最近 7 天的小时分表：time.Now().AddDate(0, 0, -7)
+func (cb *CompactOptionsBuilder) Before(before time.Time) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.before = before
+	})
+	return cb
+}
+
+// Tiers 按分层依次合并相邻两层，设置后不需要 From、To、Before
+func (cb *CompactOptionsBuilder) Tiers(tiers ...Tier) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.tiers = tiers
+	})
+	return cb
+}
+
+// Now 分层合并计算层级的当前时间，默认 time.Now()
+func (cb *CompactOptionsBuilder) Now(now time.Time) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.now = now
+	})
+	return cb
+}
+
+// Ahead 分层合并提前复制的时长，默认 1 小时：分界到达前 ahead 内的目标分表先复制、校验，分界到达后再删除源分表，
+// 需要不小于 Compact 的执行间隔
+func (cb *CompactOptionsBuilder) Ahead(ahead time.Duration) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.ahead = ahead
+	})
+	return cb
+}
+
+// TimeColumn 时间列，校验时按源分表的时间范围统计目标分表
+func (cb *CompactOptionsBuilder) TimeColumn(column string) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.timeColumn = column
+	})
+	return cb
+}
+
+// KeyColumn 分批复制使用的列，默认 id，需要有索引，合并后仍需唯一（目标分表中为主键或唯一索引），建议使用雪花 ID；
+// 目标分表中已有的 key 不再复制，内容与源分表不同时校验失败
+func (cb *CompactOptionsBuilder) KeyColumn(column string) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.keyColumn = column
+	})
+	return cb
+}
+
+// BatchSize 每批复制的行数，默认 1000
+func (cb *CompactOptionsBuilder) BatchSize(size int) *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.batchSize = size
+	})
+	return cb
+}
+
+// DryRun 只计算建表、复制、删表语句，写入 CompactResult.Plan，不执行
+func (cb *CompactOptionsBuilder) DryRun() *CompactOptionsBuilder {
+	cb.funcs = append(cb.funcs, func(opt *CompactOption) {
+		opt.dryRun = true
+	})
+	return cb
+}
@@ -1,5 +1,10 @@
 package sharding
 
+import (
+	"fmt"
+	"time"
+)
+
 // Type  分表粒度，按年，月，日....分表
 type Type int
 
@@ -9,3 +14,56 @@ const (
 	Month Type = 30 // 按月分表
 	Year  Type = 40 // 按年分表
 )
+
+// layout 分表后缀的时间格式，未识别的类型返回空字符串
+func (t Type) layout() string {
+	// 2006-01-02 15:04:05
+	switch t {
+	case Hour:
+		return "2006010215"
+	case Day:
+		return "20060102"
+	case Month:
+		return "200601"
+	case Year:
+		return "2006"
+	default:
+		return ""
+	}
+}
+
+// Bucket 时间 tm 所在分表的时间范围，左闭右开，未识别的类型返回零值
+func (t Type) Bucket(tm time.Time) (start, end time.Time) {
+	switch t {
+	case Hour:
+		start = time.Date(tm.Year(), tm.Month(), tm.Day(), tm.Hour(), 0, 0, 0, tm.Location())
+		return start, start.Add(time.Hour)
+	case Day:
+		start = time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, tm.Location())
+		return start, start.AddDate(0, 0, 1)
+	case Month:
+		start = time.Date(tm.Year(), tm.Month(), 1, 0, 0, 0, 0, tm.Location())
+		return start, start.AddDate(0, 1, 0)
+	case Year:
+		start = time.Date(tm.Year(), time.January, 1, 0, 0, 0, 0, tm.Location())
+		return start, start.AddDate(1, 0, 0)
+	default:
+		return
+	}
+}
+
+// String 分表类型名称：hour、day、month、year
+func (t Type) String() string {
+	switch t {
+	case Hour:
+		return "hour"
+	case Day:
+		return "day"
+	case Month:
+		return "month"
+	case Year:
+		return "year"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
@@ -0,0 +1,172 @@
+package sharding
+
+import (
+	"fmt"
+	"hash/crc32"
+	"regexp"
+	"strings"
+)
+
+// PartitionMode 分区子句处理方式
+type PartitionMode int
+
+const (
+	PartitionKeep    PartitionMode = iota // 原样保留基础表的分区子句
+	PartitionStrip                        // 去掉分区子句
+	PartitionRewrite                      // 使用自定义分区子句替换
+)
+
+// mysql 标识符最大长度
+const maxIdentifierLength = 64
+
+var (
+	// CREATE TABLE `user_logs` (
+	createHeadRegexp = regexp.MustCompile("^CREATE TABLE\\s+(?:IF NOT EXISTS\\s+)?(?:`(?:[^`]|``)+`\\.)?`(?:[^`]|``)+`")
+	// ) ENGINE=InnoDB AUTO_INCREMENT=1024 DEFAULT CHARSET=utf8mb4
+	autoIncrementRegexp = regexp.MustCompile(`\s+AUTO_INCREMENT=\d+`)
+	// CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`)
+	constraintRegexp = regexp.MustCompile("(?m)^(\\s*CONSTRAINT\\s+)`((?:[^`]|``)+)`(.*)$")
+)
+
+// DDLOption 分表建表语句的改写规则，由 DDLBuilder 传入
+type DDLOption struct {
+	// 分表自增起始值，0 表示去掉 AUTO_INCREMENT，从 1 开始
+	autoIncrement int64
+	// 保留基础表当前的 AUTO_INCREMENT
+	keepAutoIncrement bool
+	// 保留外键、CHECK 约束名，不做分表改名
+	keepConstraintNames bool
+	// 分区子句处理方式
+	partition PartitionMode
+	// PartitionRewrite 时使用的分区子句
+	partitionClause string
+}
+
+type DDLOptionsBuilder struct {
+	funcs []DDLOptionFunc
+}
+
+func DDLBuilder() *DDLOptionsBuilder {
+	return &DDLOptionsBuilder{}
+}
+
+type DDLOptionFunc func(*DDLOption)
+
+// AutoIncrement 分表自增起始值，默认去掉基础表的 AUTO_INCREMENT=n
+func (dl *DDLOptionsBuilder) AutoIncrement(start int64) *DDLOptionsBuilder {
+	dl.funcs = append(dl.funcs, func(opt *DDLOption) {
+		opt.autoIncrement = start
+	})
+	return dl
+}
+
+// KeepAutoIncrement 保留基础表当前的 AUTO_INCREMENT=n
+func (dl *DDLOptionsBuilder) KeepAutoIncrement() *DDLOptionsBuilder {
+	dl.funcs = append(dl.funcs, func(opt *DDLOption) {
+		opt.keepAutoIncrement = true
+	})
+	return dl
+}
+
+// KeepConstraintNames 不改写约束名，注意mysql要求约束名在库内唯一
+func (dl *DDLOptionsBuilder) KeepConstraintNames() *DDLOptionsBuilder {
+	dl.funcs = append(dl.funcs, func(opt *DDLOption) {
+		opt.keepConstraintNames = true
+	})
+	return dl
+}
+
+// StripPartition 去掉基础表的分区子句
+func (dl *DDLOptionsBuilder) StripPartition() *DDLOptionsBuilder {
+	dl.funcs = append(dl.funcs, func(opt *DDLOption) {
+		opt.partition = PartitionStrip
+	})
+	return dl
+}
+
+// RewritePartition 使用 clause 替换基础表的分区子句，例如：PARTITION BY HASH(`user_id`) PARTITIONS 8
+func (dl *DDLOptionsBuilder) RewritePartition(clause string) *DDLOptionsBuilder {
+	dl.funcs = append(dl.funcs, func(opt *DDLOption) {
+		opt.partition = PartitionRewrite
+		opt.partitionClause = clause
+	})
+	return dl
+}
+
+func (dl *DDLOptionsBuilder) build() *DDLOption {
+	option := new(DDLOption)
+	if dl == nil {
+		return option
+	}
+	for _, opf := range dl.funcs {
+		opf(option)
+	}
+	return option
+}
+
+// TransformDDL 将基础表 SHOW CREATE TABLE 的结果改写成分表建表语句
+// createSql 基础表建表语句；db 库名；primary 基础表名；table 分表名；builder 改写规则，可以为nil
+func TransformDDL(createSql, db, primary, table string, builder *DDLOptionsBuilder) (string, error) {
+	option := builder.build()
+	if option.partition == PartitionRewrite && strings.TrimSpace(option.partitionClause) == "" {
+		return "", invalidOption("RewritePartition", "sharding.TransformDDL，RewritePartition 分区子句不能为空")
+	}
+	if !createHeadRegexp.MatchString(createSql) {
+		return "", fmt.Errorf("sharding.TransformDDL，建表语句无法识别：%.64s", createSql)
+	}
+	createSql = createHeadRegexp.ReplaceAllLiteralString(createSql, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s", quote(db), quote(table)))
+
+	// SHOW CREATE TABLE 的格式：列和索引定义每行缩进，表选项行以 ")" 开头，分区子句在表选项之后
+	var body, options, partition = createSql, "", ""
+	if i := strings.LastIndex(createSql, "\n)"); i >= 0 {
+		body, options = createSql[:i+1], createSql[i+1:]
+		if j := strings.Index(options, "\n"); j >= 0 {
+			options, partition = options[:j], options[j:]
+		}
+	}
+
+	// 约束名库内唯一，按分表改名；自关联外键指向分表自身
+	if !option.keepConstraintNames {
+		body = constraintRegexp.ReplaceAllStringFunc(body, func(line string) string {
+			m := constraintRegexp.FindStringSubmatch(line)
+			name := strings.ReplaceAll(m[2], "``", "`")
+			rest := strings.Replace(m[3], "REFERENCES "+quote(primary)+" ", "REFERENCES "+quote(table)+" ", 1)
+			return m[1] + quote(constraintName(name, primary, table)) + rest
+		})
+	}
+
+	if !option.keepAutoIncrement {
+		options = autoIncrementRegexp.ReplaceAllString(options, "")
+		if option.autoIncrement > 0 {
+			options = fmt.Sprintf("%s AUTO_INCREMENT=%d", options, option.autoIncrement)
+		}
+	}
+
+	switch option.partition {
+	case PartitionStrip:
+		partition = ""
+	case PartitionRewrite:
+		partition = "\n" + strings.TrimSpace(option.partitionClause)
+	}
+	return body + options + partition, nil
+}
+
+// constraintName 分表约束名：包含基础表名的替换成分表名，否则追加分表后缀，超长时截断并拼接校验码
+func constraintName(name, primary, table string) string {
+	var renamed string
+	if strings.Contains(name, primary) {
+		renamed = strings.Replace(name, primary, table, 1)
+	} else {
+		renamed = fmt.Sprintf("%s_%s", name, strings.TrimPrefix(table, primary+"_"))
+	}
+	if len(renamed) <= maxIdentifierLength {
+		return renamed
+	}
+	var sum = fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(renamed)))
+	return renamed[:maxIdentifierLength-len(sum)-1] + "_" + sum
+}
+
+// quote mysql 标识符加反引号
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
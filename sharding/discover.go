@@ -0,0 +1,87 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Shard 库中已存在的分表
+type Shard struct {
+	// 分表名
+	Table string
+	// 分表类型，由分表后缀长度推断
+	Type Type
+	// 分表时间范围，左闭右开
+	Start time.Time
+	End   time.Time
+}
+
+// ParseShard 解析分表名，table 不是 primary 按时间分表的命名时返回false，时间按 loc 时区解析
+func ParseShard(primary, table string, loc *time.Location) (*Shard, bool) {
+	if !strings.HasPrefix(table, primary+"_") {
+		return nil, false
+	}
+	var suffix = strings.TrimPrefix(table, primary+"_")
+	for _, t := range []Type{Hour, Day, Month, Year} {
+		var layout = t.layout()
+		if len(suffix) != len(layout) {
+			continue
+		}
+		tm, err := time.ParseInLocation(layout, suffix, loc)
+		if err != nil || tm.Format(layout) != suffix {
+			return nil, false
+		}
+		var start, end = t.Bucket(tm)
+		return &Shard{Table: table, Type: t, Start: start, End: end}, true
+	}
+	return nil, false
+}
+
+// ListShards 列出库 db 中基础表 primary 的所有分表，按时间排序，分表时间按本地时区解析
+func ListShards(ctx context.Context, client *sql.DB, db, primary string) ([]*Shard, error) {
+	tables, err := listTables(ctx, client, db, primary)
+	if err != nil {
+		return nil, err
+	}
+	var shards = make([]*Shard, 0, len(tables))
+	for _, table := range tables {
+		if shard, ok := ParseShard(primary, table, time.Local); ok {
+			shards = append(shards, shard)
+		}
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		if !shards[i].Start.Equal(shards[j].Start) {
+			return shards[i].Start.Before(shards[j].Start)
+		}
+		return shards[i].Type < shards[j].Type
+	})
+	return shards, nil
+}
+
+// listTables 列出库 db 中以 primary_ 开头的所有表名
+func listTables(ctx context.Context, client *sql.DB, db, primary string) ([]string, error) {
+	const query = "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME LIKE ?"
+	rows, err := client.QueryContext(ctx, query, db, likePrefix(primary+"_"))
+	if err != nil {
+		return nil, fmt.Errorf("sharding.ListShards，分表查询失败：%w", err)
+	}
+	defer rows.Close()
+	var tables = make([]string, 0)
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// likePrefix 前缀匹配的 LIKE 条件，转义 \ % _
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
@@ -0,0 +1,217 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DriftKind 分表结构差异类型
+type DriftKind string
+
+const (
+	DriftMissing DriftKind = "missing" // 基础表有，分表没有
+	DriftExtra   DriftKind = "extra"   // 分表有，基础表没有
+	DriftChanged DriftKind = "changed" // 两边都有，定义不同
+)
+
+// Drift 一处结构差异
+type Drift struct {
+	// 差异类型
+	Kind DriftKind `json:"kind"`
+	// 列名、索引名或表属性名
+	Name string `json:"name"`
+	// 基础表中的定义，DriftExtra 时为空
+	Expect string `json:"expect,omitempty"`
+	// 分表中的定义，DriftMissing 时为空
+	Actual string `json:"actual,omitempty"`
+}
+
+// DriftReport 单张分表与基础表的结构差异
+type DriftReport struct {
+	// 分表名
+	Table string `json:"table"`
+	// 列差异，比较类型、是否可空、默认值、extra、排序规则和列顺序
+	Columns []*Drift `json:"columns,omitempty"`
+	// 索引差异，比较唯一性、索引类型和索引列
+	Indexes []*Drift `json:"indexes,omitempty"`
+	// 表属性差异，比较存储引擎和排序规则（字符集）
+	Options []*Drift `json:"options,omitempty"`
+}
+
+// HasDrift 分表结构是否与基础表不一致
+func (dr *DriftReport) HasDrift() bool {
+	return len(dr.Columns) > 0 || len(dr.Indexes) > 0 || len(dr.Options) > 0
+}
+
+// tableDefinition information_schema 中的表结构，key 为列名、索引名或表属性名
+type tableDefinition struct {
+	columns map[string]string
+	indexes map[string]string
+	options map[string]string
+}
+
+// CheckDrift 通过 information_schema 比较库 db 中基础表 primary 与所有分表的结构，每张分表返回一份报告
+func CheckDrift(ctx context.Context, client *sql.DB, db, primary string) ([]*DriftReport, error) {
+	shards, err := ListShards(ctx, client, db, primary)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := loadDefinitions(ctx, client, db, primary)
+	if err != nil {
+		return nil, err
+	}
+	base, ok := definitions[primary]
+	if !ok {
+		return nil, fmt.Errorf("%w：%s.%s", ErrBaseTableMissing, db, primary)
+	}
+	var reports = make([]*DriftReport, 0, len(shards))
+	for _, shard := range shards {
+		var actual = definitions[shard.Table]
+		if actual == nil {
+			// 查询期间被删除的分表
+			continue
+		}
+		reports = append(reports, &DriftReport{
+			Table:   shard.Table,
+			Columns: diffDefinition(base.columns, actual.columns),
+			Indexes: diffDefinition(base.indexes, actual.indexes),
+			Options: diffDefinition(base.options, actual.options),
+		})
+	}
+	return reports, nil
+}
+
+// diffDefinition 比较两份定义，结果按名称排序
+func diffDefinition(expect, actual map[string]string) []*Drift {
+	var drifts = make([]*Drift, 0)
+	for name, definition := range expect {
+		if got, ok := actual[name]; !ok {
+			drifts = append(drifts, &Drift{Kind: DriftMissing, Name: name, Expect: definition})
+		} else if got != definition {
+			drifts = append(drifts, &Drift{Kind: DriftChanged, Name: name, Expect: definition, Actual: got})
+		}
+	}
+	for name, definition := range actual {
+		if _, ok := expect[name]; !ok {
+			drifts = append(drifts, &Drift{Kind: DriftExtra, Name: name, Actual: definition})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Name < drifts[j].Name })
+	return drifts
+}
+
+// loadDefinitions 一次性读取基础表和所有 primary_ 开头的表结构
+func loadDefinitions(ctx context.Context, client *sql.DB, db, primary string) (map[string]*tableDefinition, error) {
+	var definitions = make(map[string]*tableDefinition)
+	var definition = func(table string) *tableDefinition {
+		if _, ok := definitions[table]; !ok {
+			definitions[table] = &tableDefinition{columns: map[string]string{}, indexes: map[string]string{}, options: map[string]string{}}
+		}
+		return definitions[table]
+	}
+	var like = likePrefix(primary + "_")
+
+	// 表属性
+	const tableSql = "SELECT TABLE_NAME, IFNULL(ENGINE, ''), IFNULL(TABLE_COLLATION, '') FROM information_schema.TABLES " +
+		"WHERE TABLE_SCHEMA = ? AND (TABLE_NAME = ? OR TABLE_NAME LIKE ?)"
+	err := queryEach(ctx, client, tableSql, []any{db, primary, like}, func(rows *sql.Rows) error {
+		var table, engine, collation string
+		if err := rows.Scan(&table, &engine, &collation); err != nil {
+			return err
+		}
+		var def = definition(table)
+		def.options["engine"] = engine
+		def.options["collation"] = collation
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sharding.CheckDrift，表信息查询失败：%w", err)
+	}
+
+	// 列
+	const columnSql = "SELECT TABLE_NAME, COLUMN_NAME, ORDINAL_POSITION, COLUMN_TYPE, IS_NULLABLE, " +
+		"COLUMN_DEFAULT IS NULL, IFNULL(COLUMN_DEFAULT, ''), EXTRA, IFNULL(COLLATION_NAME, '') FROM information_schema.COLUMNS " +
+		"WHERE TABLE_SCHEMA = ? AND (TABLE_NAME = ? OR TABLE_NAME LIKE ?)"
+	err = queryEach(ctx, client, columnSql, []any{db, primary, like}, func(rows *sql.Rows) error {
+		var table, column, columnType, nullable, defaultValue, extra, collation string
+		var position int
+		var noDefault bool
+		if err := rows.Scan(&table, &column, &position, &columnType, &nullable, &noDefault, &defaultValue, &extra, &collation); err != nil {
+			return err
+		}
+		var def = fmt.Sprintf("#%d %s", position, columnType)
+		if nullable == "NO" {
+			def += " NOT NULL"
+		}
+		// 没有默认值时不输出 DEFAULT，与默认值为字符串 'NULL' 区分
+		if !noDefault {
+			def += " DEFAULT " + defaultValue
+		}
+		if extra != "" {
+			def += " " + extra
+		}
+		if collation != "" {
+			def += " COLLATE " + collation
+		}
+		definition(table).columns[column] = def
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sharding.CheckDrift，列信息查询失败：%w", err)
+	}
+
+	// 索引
+	const indexSql = "SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, INDEX_TYPE, COLUMN_NAME, IFNULL(SUB_PART, 0) FROM information_schema.STATISTICS " +
+		"WHERE TABLE_SCHEMA = ? AND (TABLE_NAME = ? OR TABLE_NAME LIKE ?) ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX"
+	var indexColumns = make(map[[2]string][]string)
+	var indexHeads = make(map[[2]string]string)
+	err = queryEach(ctx, client, indexSql, []any{db, primary, like}, func(rows *sql.Rows) error {
+		var table, index, indexType string
+		var column sql.NullString
+		var nonUnique, subPart int
+		if err := rows.Scan(&table, &index, &nonUnique, &indexType, &column, &subPart); err != nil {
+			return err
+		}
+		var key = [2]string{table, index}
+		if nonUnique == 0 {
+			indexHeads[key] = "UNIQUE " + indexType
+		} else {
+			indexHeads[key] = indexType
+		}
+		// 函数索引没有列名
+		var name = column.String
+		if !column.Valid {
+			name = "(expression)"
+		}
+		if subPart > 0 {
+			name = fmt.Sprintf("%s(%d)", name, subPart)
+		}
+		indexColumns[key] = append(indexColumns[key], name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sharding.CheckDrift，索引信息查询失败：%w", err)
+	}
+	for key, columns := range indexColumns {
+		definition(key[0]).indexes[key[1]] = fmt.Sprintf("%s (%s)", indexHeads[key], strings.Join(columns, ","))
+	}
+	return definitions, nil
+}
+
+// queryEach 执行查询，逐行回调
+func queryEach(ctx context.Context, client *sql.DB, query string, args []any, fn func(rows *sql.Rows) error) error {
+	rows, err := client.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
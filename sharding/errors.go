@@ -0,0 +1,62 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrLockTimeout 建表分布式锁等待超时，可以重试
+	ErrLockTimeout = errors.New("sharding.GetTableName，分布式锁获取失败")
+	// ErrBaseTableMissing 基础表不存在，无法复制表结构，重试无效
+	ErrBaseTableMissing = errors.New("sharding，基础表不存在")
+	// ErrUnknownType 分表类型不识别
+	ErrUnknownType = errors.New("sharding，分表类型不识别")
+	// ErrNoShard key 没有对应的分表，例如超出 RangeLookup 的范围
+	ErrNoShard = errors.New("sharding，key 没有对应的分表")
+	// ErrNoPlacement 分表没有匹配的放置规则
+	ErrNoPlacement = errors.New("sharding，分表没有匹配的数据库")
+)
+
+// ErrInvalidOption 参数校验失败，Field 为 builder 中的方法名，例如 Primary、ThisTime
+type ErrInvalidOption struct {
+	Field string
+	Msg   string
+	Cause error
+}
+
+func (e *ErrInvalidOption) Error() string {
+	if e.Msg != "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("sharding，option %s 不合法", e.Field)
+}
+
+func (e *ErrInvalidOption) Unwrap() error {
+	return e.Cause
+}
+
+// Is errors.Is(err, &ErrInvalidOption{}) 匹配任意参数错误，设置 Field 时只匹配该参数
+func (e *ErrInvalidOption) Is(target error) bool {
+	t, ok := target.(*ErrInvalidOption)
+	return ok && (t.Field == "" || t.Field == e.Field)
+}
+
+// invalidOption 参数校验失败
+func invalidOption(field, msg string) error {
+	return &ErrInvalidOption{Field: field, Msg: msg}
+}
+
+// DDLError 建表、删表、结构变更等语句执行失败，Cause 为 mysql 返回的原始错误
+type DDLError struct {
+	SQL   string
+	Cause error
+}
+
+func (e *DDLError) Error() string {
+	return fmt.Sprintf("sharding，ddl 执行失败：%v，sql：%s", e.Cause, e.SQL)
+}
+
+func (e *DDLError) Unwrap() error {
+	return e.Cause
+}
@@ -0,0 +1,117 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+// ForEachShard 对 Params 拆分出的每张分表执行 fn，用于跨分表查询，每张分表一个 span
+// fn 的 ctx 带有该分表的 span，错误合并返回，不会中断其他分表；ctx 取消后不再调度剩余分表，错误中包含 ctx 的错误
+// builder 为 nil 时按 FanOutBuilder() 默认参数执行
+func ForEachShard(ctx context.Context, builder *FanOutOptionsBuilder, params []*ParamsResult, fn func(ctx context.Context, param *ParamsResult) error) error {
+	if builder == nil {
+		builder = FanOutBuilder()
+	}
+	option := new(FanOutOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if option.concurrency <= 0 {
+		option.concurrency = 1
+	}
+	ctx, span := startSpan(ctx, option.tracerProvider, "sharding.ForEachShard", attrShards.Int(len(params)))
+	var (
+		mu   sync.Mutex
+		errs = make([]error, 0)
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, option.concurrency)
+		// 已调度的分表数，ctx 取消后剩余分表不再执行
+		scheduled int
+	)
+schedule:
+	for _, param := range params {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break schedule
+		}
+		scheduled++
+		wg.Add(1)
+		go func(param *ParamsResult) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := option.query(ctx, param, fn); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("sharding.ForEachShard，%s：%w", param.TableName, err))
+				mu.Unlock()
+			}
+		}(param)
+	}
+	wg.Wait()
+	if scheduled < len(params) {
+		errs = append(errs, fmt.Errorf("sharding.ForEachShard，%d 张分表未执行：%w", len(params)-scheduled, ctx.Err()))
+	}
+	var err = errors.Join(errs...)
+	endSpan(span, err)
+	return err
+}
+
+// query 单张分表查询，panic 转为错误
+func (fo *FanOutOption) query(ctx context.Context, param *ParamsResult, fn func(ctx context.Context, param *ParamsResult) error) (err error) {
+	ctx, span := startSpan(ctx, fo.tracerProvider, "sharding.query",
+		attrShard.String(param.TableName),
+		attrStart.String(param.Start.Format(time.RFC3339)),
+		attrEnd.String(param.End.Format(time.RFC3339)),
+		attribute.Bool("sharding.end_close", param.IsEndClose))
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic：%v", r)
+		}
+		endSpan(span, err)
+	}()
+	return fn(ctx, param)
+}
+
+// FanOutOption 跨分表查询参数，由 FanOutBuilder 传入
+type FanOutOption struct {
+	// 并发数，默认 1，按分表顺序依次执行
+	concurrency int
+	// 链路追踪，默认不产生 span
+	tracerProvider trace.TracerProvider
+}
+
+type FanOutOptionsBuilder struct {
+	funcs []FanOutOptionFunc
+}
+
+func FanOutBuilder() *FanOutOptionsBuilder {
+	return &FanOutOptionsBuilder{}
+}
+
+type FanOutOptionFunc func(*FanOutOption)
+
+// Concurrency 同时查询的分表数，默认 1
+func (fb *FanOutOptionsBuilder) Concurrency(concurrency int) *FanOutOptionsBuilder {
+	fb.funcs = append(fb.funcs, func(opt *FanOutOption) {
+		opt.concurrency = concurrency
+	})
+	return fb
+}
+
+// TracerProvider 设置链路追踪，每次调用一个 sharding.ForEachShard span，每张分表一个 sharding.query 子 span
+func (fb *FanOutOptionsBuilder) TracerProvider(tp trace.TracerProvider) *FanOutOptionsBuilder {
+	fb.funcs = append(fb.funcs, func(opt *FanOutOption) {
+		opt.tracerProvider = tp
+	})
+	return fb
+}
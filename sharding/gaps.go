@@ -0,0 +1,126 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Gap 缺少的分表
+type Gap struct {
+	// 分表名
+	Table string `json:"table"`
+	// 分表时间范围，左闭右开
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// GapReport 分表缺失检查结果
+type GapReport struct {
+	// 时间范围内应该存在但不存在的分表，按时间排序
+	Missing []*Gap `json:"missing"`
+	// primary_ 开头但不符合分表布局命名规则的表，例如手工备份表、其他粒度的分表
+	Extra []string `json:"extra"`
+}
+
+// HasGap 是否有缺少的分表
+func (gr *GapReport) HasGap() bool {
+	return len(gr.Missing) > 0
+}
+
+// FindGaps 比较 [start, end) 内 Params() 拆分出的分表与库 db 中实际存在的表，
+// 返回缺少的分表（写入服务宕机、建表失败等）和不符合命名规则的多余表，分表粒度调整、分层分表使用 FindGapsParams
+func FindGaps(ctx context.Context, client *sql.DB, db, primary string, t Type, start, end time.Time) (*GapReport, error) {
+	return FindGapsParams(ctx, client, db, ParamsBuilder().Primary(primary).Start(start).End(end).Type(t))
+}
+
+// FindGapsParams 与 FindGaps 相同，分表布局由 builder 决定，支持 Type、Schedule、Tiers，不支持 Keys、Placement：
+// 设置 Schedule 时每张表按其时间范围内生效的调整计划检查分表类型和分表名前缀，设置 Tiers 时任一层的分表类型都不算多余
+func FindGapsParams(ctx context.Context, client *sql.DB, db string, builder *ParamsOptionsBuilder) (*GapReport, error) {
+	option := new(ParamsOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if option.keyStrategy != nil || option.placement != nil {
+		return nil, invalidOption("Params", "sharding.FindGaps，不支持 Keys、Placement")
+	}
+	params, err := Params(builder)
+	if err != nil {
+		return nil, err
+	}
+	// 调整计划启用新的分表名前缀时，同时列出新前缀的表
+	var primaries = []string{option.primary}
+	for _, cutover := range option.schedule {
+		if cutover.Primary != "" && !slices.Contains(primaries, cutover.Primary) {
+			primaries = append(primaries, cutover.Primary)
+		}
+	}
+	var report = &GapReport{Missing: make([]*Gap, 0), Extra: make([]string, 0)}
+	var exists = make(map[string]bool)
+	for _, primary := range primaries {
+		tables, err := listTables(ctx, client, db, primary)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			if exists[table] {
+				continue
+			}
+			exists[table] = true
+			if !option.owns(primaries, table) {
+				report.Extra = append(report.Extra, table)
+			}
+		}
+	}
+	sort.Strings(report.Extra)
+	for _, param := range params {
+		if exists[param.TableName] {
+			continue
+		}
+		var bucketStart, bucketEnd = param.Type.Bucket(param.Start)
+		report.Missing = append(report.Missing, &Gap{Table: param.TableName, Start: bucketStart, End: bucketEnd})
+	}
+	return report, nil
+}
+
+// owns 表 table 是否符合分表布局的命名规则
+func (po *ParamsOption) owns(primaries []string, table string) bool {
+	for _, primary := range primaries {
+		shard, ok := ParseShard(primary, table, po.start.Location())
+		if !ok {
+			continue
+		}
+		switch {
+		case len(po.schedule) > 0:
+			// 调整时间不在分表边界时，跨调整时间的分表前后两种粒度都可能存在
+			for i, cutover := range po.schedule {
+				if i+1 < len(po.schedule) && !shard.Start.Before(po.schedule[i+1].From) {
+					continue
+				}
+				if i > 0 && !cutover.From.Before(shard.End) {
+					break
+				}
+				var expect = po.primary
+				if cutover.Primary != "" {
+					expect = cutover.Primary
+				}
+				if expect == primary && cutover.Type == shard.Type {
+					return true
+				}
+			}
+		case len(po.tiers) > 0:
+			for _, tier := range po.tiers {
+				if tier.Type == shard.Type {
+					return true
+				}
+			}
+		default:
+			if primary == po.primary && shard.Type == po.t {
+				return true
+			}
+		}
+	}
+	return false
+}
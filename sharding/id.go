@@ -0,0 +1,130 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ID 结构：1 位符号位 + 41 位毫秒时间戳 + 10 位节点 + 12 位序列号，时间戳从 epoch 开始，可用约 69 年
+const (
+	idNodeBits     = 10
+	idSequenceBits = 12
+	idTimeBits     = 41
+	// MaxIDNode 节点编号最大值
+	MaxIDNode     = 1<<idNodeBits - 1
+	idMaxSequence = 1<<idSequenceBits - 1
+	idMaxTime     = 1<<idTimeBits - 1
+	// 时钟回拨不超过该值时等待追上，超过时返回错误
+	idMaxBackwards = 10 * time.Millisecond
+)
+
+// DefaultEpoch 默认 ID 时间起点
+var DefaultEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ErrClockBackwards 系统时钟回拨超过 10 毫秒，生成的 ID 可能重复
+var ErrClockBackwards = errors.New("sharding.IDGenerator，系统时钟回拨")
+
+// IDGenerator 雪花 ID 生成器，ID 中带有毫秒时间戳，可以直接由 ID 找到分表，见 ShardForID
+type IDGenerator struct {
+	epoch time.Time
+	node  int64
+	mu    sync.Mutex
+	last  int64
+	seq   int64
+}
+
+// NewIDGenerator ID 生成器，node 为节点编号，0 ~ MaxIDNode，同一时刻每个实例的 node 必须不同
+func NewIDGenerator(node int64) (*IDGenerator, error) {
+	if node < 0 || node > MaxIDNode {
+		return nil, invalidOption("Node", fmt.Sprintf("sharding.NewIDGenerator，node %d 超出范围 0 ~ %d", node, MaxIDNode))
+	}
+	return &IDGenerator{epoch: DefaultEpoch, node: node}, nil
+}
+
+// Epoch 时间起点，默认 DefaultEpoch，需要在生成 ID 前设置，解析 ID 时使用相同的起点
+func (g *IDGenerator) Epoch(epoch time.Time) *IDGenerator {
+	g.epoch = epoch
+	return g
+}
+
+// Next 生成 ID，同一毫秒内序列号用完时等待下一毫秒
+func (g *IDGenerator) Next() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var now = time.Since(g.epoch).Milliseconds()
+	if now < g.last {
+		// 小幅回拨时等待追上
+		var backwards = time.Duration(g.last-now) * time.Millisecond
+		if backwards > idMaxBackwards {
+			return 0, fmt.Errorf("%w：%s", ErrClockBackwards, backwards)
+		}
+		now = g.wait(now)
+	}
+	if now == g.last {
+		g.seq = (g.seq + 1) & idMaxSequence
+		if g.seq == 0 {
+			now = g.wait(now)
+		}
+	} else {
+		g.seq = 0
+	}
+	if now < 0 || now > idMaxTime {
+		return 0, invalidOption("Epoch", fmt.Sprintf("sharding.IDGenerator，当前时间超出 epoch %s 的范围", g.epoch.Format(time.DateTime)))
+	}
+	g.last = now
+	return now<<(idNodeBits+idSequenceBits) | g.node<<idSequenceBits | g.seq, nil
+}
+
+// wait 等待时间超过上次生成的毫秒
+func (g *IDGenerator) wait(now int64) int64 {
+	for now <= g.last {
+		time.Sleep(100 * time.Microsecond)
+		now = time.Since(g.epoch).Milliseconds()
+	}
+	return now
+}
+
+// Time ID 的生成时间，本地时区
+func (g *IDGenerator) Time(id int64) time.Time {
+	return idTime(g.epoch, id)
+}
+
+// ShardForID ID 所在的分表，见 ShardForID
+func (g *IDGenerator) ShardForID(id int64, primary string, t Type, loc *time.Location) (string, error) {
+	return shardForID(g.epoch, id, primary, t, loc)
+}
+
+// IDTime 使用 DefaultEpoch 的 ID 的生成时间，本地时区
+func IDTime(id int64) time.Time {
+	return idTime(DefaultEpoch, id)
+}
+
+// IDNode 生成 ID 的节点编号
+func IDNode(id int64) int64 {
+	return (id >> idSequenceBits) & MaxIDNode
+}
+
+// ShardForID 使用 DefaultEpoch 的 ID 所在的分表，写入时需要以 IDTime(id) 作为分表时间（ThisTime），
+// 按 ID 查询时只需要查询一张分表；loc 为写入时分表名使用的时区，需要与 ThisTime、Unix 的时区一致，为 nil 时使用 time.Local
+func ShardForID(id int64, primary string, t Type, loc *time.Location) (string, error) {
+	return shardForID(DefaultEpoch, id, primary, t, loc)
+}
+
+func idTime(epoch time.Time, id int64) time.Time {
+	return epoch.Add(time.Duration(id>>(idNodeBits+idSequenceBits)) * time.Millisecond).Local()
+}
+
+func shardForID(epoch time.Time, id int64, primary string, t Type, loc *time.Location) (string, error) {
+	if id <= 0 {
+		return "", invalidOption("ID", fmt.Sprintf("sharding.ShardForID，id %d 不合法", id))
+	}
+	if t.layout() == "" {
+		return "", fmt.Errorf("sharding.ShardForID，type %d：%w", t, ErrUnknownType)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return fmt.Sprintf("%s_%s", primary, idTime(epoch, id).In(loc).Format(t.layout())), nil
+}
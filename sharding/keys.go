@@ -0,0 +1,226 @@
+package sharding
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// KeyStrategy 按 key 分表的策略，key 映射到分表后缀，分表名为 primary_后缀
+type KeyStrategy interface {
+	// Shard key 所在分表的后缀
+	Shard(key int64) (string, error)
+	// Shards 所有分表后缀，用于全表扫描、预建表
+	Shards() []string
+}
+
+// shardSuffixRegexp 分表后缀只允许字母、数字、下划线
+var shardSuffixRegexp = regexp.MustCompile(`^[0-9A-Za-z_]+$`)
+
+// shardSuffix 计算 key 的分表后缀并校验
+func shardSuffix(strategy KeyStrategy, key int64) (string, error) {
+	suffix, err := strategy.Shard(key)
+	if err != nil {
+		return "", err
+	}
+	if err = checkShardSuffix(suffix); err != nil {
+		return "", err
+	}
+	return suffix, nil
+}
+
+// checkShardSuffix 校验分表后缀：只允许字母、数字、下划线，且不能是与按时间分表后缀长度相同的纯数字，
+// 否则 ParseShard 会把 key 分表当作按时间分表，被 Retain、Compact 等按时间维护的方法删除
+func checkShardSuffix(suffix string) error {
+	if !shardSuffixRegexp.MatchString(suffix) {
+		return invalidOption("Key", fmt.Sprintf("sharding，分表后缀只允许字母、数字、下划线：%q", suffix))
+	}
+	if timeLikeSuffix(suffix) {
+		return invalidOption("Key", fmt.Sprintf("sharding，分表后缀 %q 与按时间分表的后缀混淆，纯数字后缀不能是 4、6、8、10 位", suffix))
+	}
+	return nil
+}
+
+// timeLikeSuffix 后缀是否为与按时间分表后缀长度相同的纯数字
+func timeLikeSuffix(suffix string) bool {
+	for _, c := range suffix {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	for _, t := range []Type{Hour, Day, Month, Year} {
+		if len(suffix) == len(t.layout()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Modulo 取模分表，key % N，后缀按 N-1 的位数补零，例如 N=64 时 user_00 ~ user_63；
+// 位数为 4、6、8、10 时多补一位（例如 N=10000 时 user_00000 ~ user_09999），避免与按时间分表的后缀混淆
+type Modulo struct {
+	N int64
+}
+
+func (m Modulo) Shard(key int64) (string, error) {
+	if m.N <= 0 {
+		return "", invalidOption("Key", "sharding.Modulo，N 必须大于0")
+	}
+	return m.format((key%m.N + m.N) % m.N), nil
+}
+
+func (m Modulo) Shards() []string {
+	var shards = make([]string, 0, max(m.N, 0))
+	for i := int64(0); i < m.N; i++ {
+		shards = append(shards, m.format(i))
+	}
+	return shards
+}
+
+func (m Modulo) format(i int64) string {
+	var width = len(strconv.FormatInt(m.N-1, 10))
+	if timeLikeSuffix(strings.Repeat("0", width)) {
+		width++
+	}
+	return fmt.Sprintf("%0*d", width, i)
+}
+
+// Identity 每个 key 一张分表，后缀为 Prefix+key，例如 Prefix 为 t 时租户 42 的分表为 orders_t42
+// Prefix 为空时 key 为 4、6、8、10 位数的分表返回 ErrInvalidOption，通常需要设置 Prefix
+// 分表数量不固定，Shards 返回空
+type Identity struct {
+	Prefix string
+}
+
+func (id Identity) Shard(key int64) (string, error) {
+	if key < 0 {
+		return "", invalidOption("Key", fmt.Sprintf("sharding.Identity，key 不能为负数：%d", key))
+	}
+	return id.Prefix + strconv.FormatInt(key, 10), nil
+}
+
+func (id Identity) Shards() []string {
+	return nil
+}
+
+// ConsistentHash 一致性哈希分表，每个节点（分表后缀）在哈希环上有 replicas 个虚拟节点，增删节点时只迁移少量 key
+type ConsistentHash struct {
+	nodes []string
+	ring  []uint64
+	owner map[uint64]string
+}
+
+// NewConsistentHash 一致性哈希，replicas<=0 时默认 160 个虚拟节点，nodes 为分表后缀，重复的忽略；
+// 节点名不能是 4、6、8、10 位纯数字（例如 2024），否则分表时返回 ErrInvalidOption
+func NewConsistentHash(replicas int, nodes ...string) *ConsistentHash {
+	if replicas <= 0 {
+		replicas = 160
+	}
+	var ch = &ConsistentHash{owner: make(map[uint64]string)}
+	var seen = make(map[string]bool)
+	for _, node := range nodes {
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		ch.nodes = append(ch.nodes, node)
+		for i := 0; i < replicas; i++ {
+			var point = hashKey(node + "#" + strconv.Itoa(i))
+			if _, ok := ch.owner[point]; ok {
+				// 哈希冲突，保留先加入的节点
+				continue
+			}
+			ch.owner[point] = node
+			ch.ring = append(ch.ring, point)
+		}
+	}
+	sort.Slice(ch.ring, func(i, j int) bool { return ch.ring[i] < ch.ring[j] })
+	sort.Strings(ch.nodes)
+	return ch
+}
+
+func (ch *ConsistentHash) Shard(key int64) (string, error) {
+	if len(ch.ring) == 0 {
+		return "", invalidOption("Key", "sharding.ConsistentHash，没有节点")
+	}
+	var point = hashKey(strconv.FormatInt(key, 10))
+	var i = sort.Search(len(ch.ring), func(i int) bool { return ch.ring[i] >= point })
+	if i == len(ch.ring) {
+		i = 0
+	}
+	return ch.owner[ch.ring[i]], nil
+}
+
+func (ch *ConsistentHash) Shards() []string {
+	return append([]string(nil), ch.nodes...)
+}
+
+// hashKey fnv-1a 哈希，再用 murmur3 的 fmix64 打散，短字符串的 fnv 高位分布不均匀
+func hashKey(key string) uint64 {
+	var h = fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	var x = h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// KeyRange key 范围，左闭右开
+type KeyRange struct {
+	Min   int64
+	Max   int64
+	Shard string
+}
+
+// RangeLookup 按 key 范围查表分表，例如 [0, 1000000) -> 00，[1000000, 2000000) -> 01
+type RangeLookup struct {
+	ranges []KeyRange
+	err    error
+}
+
+// NewRangeLookup 范围查表，范围不能为空、不能重叠
+func NewRangeLookup(ranges ...KeyRange) *RangeLookup {
+	var sorted = append([]KeyRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Min < sorted[j].Min })
+	var rl = &RangeLookup{ranges: sorted}
+	for i, r := range sorted {
+		if r.Min >= r.Max {
+			rl.err = invalidOption("Key", fmt.Sprintf("sharding.RangeLookup，范围 [%d, %d) 为空", r.Min, r.Max))
+			break
+		}
+		if i > 0 && r.Min < sorted[i-1].Max {
+			rl.err = invalidOption("Key", fmt.Sprintf("sharding.RangeLookup，范围 [%d, %d) 与 [%d, %d) 重叠", sorted[i-1].Min, sorted[i-1].Max, r.Min, r.Max))
+			break
+		}
+	}
+	return rl
+}
+
+func (rl *RangeLookup) Shard(key int64) (string, error) {
+	if rl.err != nil {
+		return "", rl.err
+	}
+	var i = sort.Search(len(rl.ranges), func(i int) bool { return rl.ranges[i].Max > key })
+	if i == len(rl.ranges) || key < rl.ranges[i].Min {
+		return "", fmt.Errorf("sharding.RangeLookup，key %d：%w", key, ErrNoShard)
+	}
+	return rl.ranges[i].Shard, nil
+}
+
+func (rl *RangeLookup) Shards() []string {
+	var seen = make(map[string]bool)
+	var shards = make([]string, 0, len(rl.ranges))
+	for _, r := range rl.ranges {
+		if !seen[r.Shard] {
+			seen[r.Shard] = true
+			shards = append(shards, r.Shard)
+		}
+	}
+	return shards
+}
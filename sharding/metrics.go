@@ -0,0 +1,246 @@
+package sharding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 指标名
+const (
+	metricCacheHits       = "sharding_cache_hits_total"
+	metricCacheMisses     = "sharding_cache_misses_total"
+	metricLockAcquired    = "sharding_lock_acquired_total"
+	metricLockFailed      = "sharding_lock_failed_total"
+	metricLockWait        = "sharding_lock_wait_seconds"
+	metricLockAttempts    = "sharding_lock_attempts"
+	metricDDLExecuted     = "sharding_ddl_executed_total"
+	metricDDLErrors       = "sharding_ddl_errors_total"
+	metricDDLDuration     = "sharding_ddl_duration_seconds"
+	metricParamsSplits    = "sharding_params_splits_total"
+	metricParamsBuckets   = "sharding_params_buckets"
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// secondBuckets 耗时直方图分桶，单位秒
+	secondBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// countBuckets 次数直方图分桶
+	countBuckets = []float64{1, 2, 4, 8, 16, 32, 64, 128, 256, 512}
+)
+
+// metricHelp 指标说明，输出 prometheus 文本时使用
+var metricHelp = map[string]string{
+	metricCacheHits:     "分表存在性缓存命中次数",
+	metricCacheMisses:   "分表存在性缓存未命中次数",
+	metricLockAcquired:  "建表分布式锁获取成功次数",
+	metricLockFailed:    "建表分布式锁获取失败次数",
+	metricLockWait:      "建表分布式锁等待时长",
+	metricLockAttempts:  "建表分布式锁尝试次数",
+	metricDDLExecuted:   "建表语句执行次数",
+	metricDDLErrors:     "建表语句执行失败次数",
+	metricDDLDuration:   "建表语句执行耗时",
+	metricParamsSplits:  "Params 拆分次数",
+	metricParamsBuckets: "Params 拆分出的分表数",
+}
+
+// metricKey 指标名加标签
+type metricKey struct {
+	name    string
+	db      string
+	primary string
+}
+
+// labels prometheus 标签，空值不输出
+func (mk metricKey) labels(extra ...string) string {
+	var pairs = make([]string, 0, 3)
+	if mk.db != "" {
+		pairs = append(pairs, fmt.Sprintf("db=%q", mk.db))
+	}
+	if mk.primary != "" {
+		pairs = append(pairs, fmt.Sprintf("primary=%q", mk.primary))
+	}
+	pairs = append(pairs, extra...)
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+type histogram struct {
+	bounds []float64
+	// counts[i] 为落在 bounds[i] 内的次数（非累计），最后一个为 +Inf
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(value float64) {
+	var i = sort.SearchFloat64s(h.bounds, value)
+	h.counts[i]++
+	h.sum += value
+	h.count++
+}
+
+// Metrics Observer 的指标实现，统计计数器和直方图，按库名、基础表名打标签
+// 实现了 http.Handler，输出 prometheus 文本格式；实现了 expvar.Var，可以 expvar.Publish("sharding", metrics)
+type Metrics struct {
+	mu         sync.Mutex
+	counters   map[metricKey]float64
+	histograms map[metricKey]*histogram
+}
+
+// NewMetrics 指标收集器
+func NewMetrics() *Metrics {
+	return &Metrics{counters: make(map[metricKey]float64), histograms: make(map[metricKey]*histogram)}
+}
+
+func (m *Metrics) CacheHit(_ context.Context, db, primary, _ string) {
+	m.add(metricKey{metricCacheHits, db, primary}, 1)
+}
+
+func (m *Metrics) CacheMiss(_ context.Context, db, primary, _ string) {
+	m.add(metricKey{metricCacheMisses, db, primary}, 1)
+}
+
+func (m *Metrics) LockAcquired(_ context.Context, db, primary string, wait time.Duration, attempts int) {
+	m.add(metricKey{metricLockAcquired, db, primary}, 1)
+	m.observe(metricKey{metricLockWait, db, primary}, secondBuckets, wait.Seconds())
+	m.observe(metricKey{metricLockAttempts, db, primary}, countBuckets, float64(attempts))
+}
+
+func (m *Metrics) LockFailed(_ context.Context, db, primary string, wait time.Duration, attempts int) {
+	m.add(metricKey{metricLockFailed, db, primary}, 1)
+	m.observe(metricKey{metricLockWait, db, primary}, secondBuckets, wait.Seconds())
+	m.observe(metricKey{metricLockAttempts, db, primary}, countBuckets, float64(attempts))
+}
+
+func (m *Metrics) DDLExecuted(_ context.Context, db, primary, _ string, duration time.Duration, err error) {
+	m.add(metricKey{metricDDLExecuted, db, primary}, 1)
+	if err != nil {
+		m.add(metricKey{metricDDLErrors, db, primary}, 1)
+	}
+	m.observe(metricKey{metricDDLDuration, db, primary}, secondBuckets, duration.Seconds())
+}
+
+func (m *Metrics) ParamsSplit(_ context.Context, primary string, _ Type, buckets int) {
+	m.add(metricKey{metricParamsSplits, "", primary}, 1)
+	m.observe(metricKey{metricParamsBuckets, "", primary}, countBuckets, float64(buckets))
+}
+
+func (m *Metrics) add(key metricKey, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[key] += delta
+}
+
+func (m *Metrics) observe(key metricKey, bounds []float64, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.histograms[key]
+	if !ok {
+		h = &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+		m.histograms[key] = h
+	}
+	h.observe(value)
+}
+
+// Counter 计数器当前值，db、primary 为空表示该标签不存在
+func (m *Metrics) Counter(name, db, primary string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[metricKey{name, db, primary}]
+}
+
+// WritePrometheus 以 prometheus 文本格式输出全部指标
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var buf bytes.Buffer
+	var written = make(map[string]bool)
+	var header = func(name, kind string) {
+		if written[name] {
+			return
+		}
+		written[name] = true
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", name, metricHelp[name], name, kind)
+	}
+	for _, key := range sortedKeys(m.counters) {
+		header(key.name, "counter")
+		fmt.Fprintf(&buf, "%s%s %s\n", key.name, key.labels(), formatFloat(m.counters[key]))
+	}
+	for _, key := range sortedKeys(m.histograms) {
+		header(key.name, "histogram")
+		var h = m.histograms[key]
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&buf, "%s_bucket%s %d\n", key.name, key.labels(fmt.Sprintf("le=%q", formatFloat(bound))), cumulative)
+		}
+		fmt.Fprintf(&buf, "%s_bucket%s %d\n", key.name, key.labels(`le="+Inf"`), h.count)
+		fmt.Fprintf(&buf, "%s_sum%s %s\n", key.name, key.labels(), formatFloat(h.sum))
+		fmt.Fprintf(&buf, "%s_count%s %d\n", key.name, key.labels(), h.count)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ServeHTTP prometheus 抓取接口，例如 http.Handle("/metrics/sharding", metrics)
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", prometheusContentType)
+	_ = m.WritePrometheus(w)
+}
+
+// String expvar.Var 实现，输出 json，key 为带标签的指标名
+func (m *Metrics) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var values = make(map[string]any, len(m.counters)+len(m.histograms))
+	for key, value := range m.counters {
+		values[key.name+key.labels()] = value
+	}
+	for key, h := range m.histograms {
+		var buckets = make(map[string]uint64, len(h.bounds)+1)
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += h.counts[i]
+			buckets[formatFloat(bound)] = cumulative
+		}
+		buckets["+Inf"] = h.count
+		values[key.name+key.labels()] = map[string]any{"count": h.count, "sum": h.sum, "buckets": buckets}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+func sortedKeys[V any](values map[metricKey]V) []metricKey {
+	var keys = make([]metricKey, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		if keys[i].db != keys[j].db {
+			return keys[i].db < keys[j].db
+		}
+		return keys[i].primary < keys[j].primary
+	})
+	return keys
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
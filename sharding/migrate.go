@@ -0,0 +1,434 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/line-lee/toolkit/beankit"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// MigrationTable 迁移记录表，每张表（基础表和分表）每个已执行的版本一行
+const MigrationTable = "_sharding_migrations"
+
+// mysql 错误码：表不存在
+const errNoSuchTable = 1146
+
+// Migration 一次结构变更，SQL 为 text/template 模板，参数见 SchemaData，例如：
+// ALTER TABLE `{{.DB}}`.`{{.Table}}` ADD COLUMN `login_count` INT NOT NULL DEFAULT 0
+type Migration struct {
+	// 版本号，全局递增，按版本号从小到大执行
+	Version int64
+	// 描述
+	Name string
+	// sql 模板
+	SQL string
+
+	tpl *template.Template
+}
+
+// MigrateResult 迁移结果
+type MigrateResult struct {
+	// 本次执行的迁移，key 为表名，value 为执行的版本号
+	Applied map[string][]int64
+	// 已是最新版本，跳过的表数量
+	Skipped int
+	// 执行失败的表，key 为表名；失败的表停在失败版本之前，重新执行 Migrate 会从失败版本继续
+	Failed map[string]error
+	// DryRun 时将要执行的语句，按基础表、分表顺序排列
+	Plan *Plan
+}
+
+// Migrate 将 migrations 依次应用到基础表和所有分表，已执行的版本记录在 MigrationTable，重复执行会跳过
+// 基础表最先执行，成功后才会执行分表；基础表不存在时（使用建表模板）只执行分表
+func Migrate(ctx context.Context, builder *MigrateOptionsBuilder) (*MigrateResult, error) {
+	option := new(MigrateOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if option.mysqlClient == nil {
+		return nil, invalidOption("MysqlClient", "sharding.Migrate，option MysqlClient 必填")
+	}
+	if beankit.IsStringBlank(option.db) {
+		return nil, invalidOption("DBName", "sharding.Migrate，option DBName 必填")
+	}
+	if beankit.IsStringBlank(option.primary) {
+		return nil, invalidOption("Primary", "sharding.Migrate，option Primary 必填")
+	}
+	if beankit.IsSliceEmpty(option.migrations) {
+		return nil, invalidOption("Migrations", "sharding.Migrate，option Migrations 必填")
+	}
+	if option.concurrency <= 0 {
+		option.concurrency = 4
+	}
+	migrations, err := prepareMigrations(option.migrations)
+	if err != nil {
+		return nil, err
+	}
+	var result = newMigrateResult(option.dryRun)
+	applied, err := option.prepareRecords(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+
+	// 基础表先执行，新建的分表复制基础表结构，同时继承基础表的迁移记录
+	baseExists, err := tableExists(ctx, option.mysqlClient, option.db, option.primary)
+	if err != nil {
+		return nil, err
+	}
+	if baseExists {
+		option.migrate(ctx, &SchemaData{DB: option.db, Primary: option.primary, Table: option.primary}, migrations, applied[option.primary], result)
+		if err, ok := result.Failed[option.primary]; ok {
+			return result, fmt.Errorf("sharding.Migrate，基础表迁移失败，分表未执行：%w", err)
+		}
+	}
+
+	// 基础表执行完再查分表，期间新建的分表已经是最新结构
+	shards, err := ListShards(ctx, option.mysqlClient, option.db, option.primary)
+	if err != nil {
+		return result, err
+	}
+	if len(shards) > 0 {
+		// 迁移期间新建的分表会继承迁移记录，重新读取
+		if applied, err = option.prepareRecords(ctx, nil); err != nil {
+			return result, err
+		}
+	}
+	var wg sync.WaitGroup
+	var sem = make(chan struct{}, option.concurrency)
+	var shardResults = make([]*MigrateResult, len(shards))
+	for i, shard := range shards {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, shard *Shard) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			var data = &SchemaData{DB: option.db, Primary: option.primary, Table: shard.Table, Start: shard.Start, End: shard.End}
+			shardResults[i] = newMigrateResult(option.dryRun)
+			option.migrate(ctx, data, migrations, applied[shard.Table], shardResults[i])
+		}(i, shard)
+	}
+	wg.Wait()
+	// 按分表顺序合并，计划中的语句顺序稳定
+	for _, shardResult := range shardResults {
+		for table, versions := range shardResult.Applied {
+			result.Applied[table] = versions
+		}
+		for table, err := range shardResult.Failed {
+			result.Failed[table] = err
+		}
+		result.Skipped += shardResult.Skipped
+		if result.Plan != nil {
+			result.Plan.Merge(shardResult.Plan)
+		}
+	}
+	if len(result.Failed) > 0 {
+		var errs = make([]error, 0, len(result.Failed))
+		for table, err := range result.Failed {
+			errs = append(errs, fmt.Errorf("%s: %w", table, err))
+		}
+		return result, fmt.Errorf("sharding.Migrate，%d 张分表迁移失败：%w", len(result.Failed), errors.Join(errs...))
+	}
+	return result, nil
+}
+
+// newMigrateResult 迁移结果，dryRun 时带执行计划
+func newMigrateResult(dryRun bool) *MigrateResult {
+	var result = &MigrateResult{Applied: make(map[string][]int64), Failed: make(map[string]error)}
+	if dryRun {
+		result.Plan = new(Plan)
+	}
+	return result
+}
+
+// prepareRecords 创建迁移记录表并读取已执行的版本
+// dryRun 时不建表，记录表不存在时把建表语句写入 result 的计划，视为所有版本都未执行
+func (mo *MigrateOption) prepareRecords(ctx context.Context, result *MigrateResult) (map[string]map[int64]bool, error) {
+	if !mo.dryRun {
+		if err := ensureMigrationTable(ctx, mo.mysqlClient, mo.db); err != nil {
+			return nil, err
+		}
+		return appliedVersions(ctx, mo.mysqlClient, mo.db, mo.primary)
+	}
+	exists, err := tableExists(ctx, mo.mysqlClient, mo.db, MigrationTable)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return appliedVersions(ctx, mo.mysqlClient, mo.db, mo.primary)
+	}
+	if result != nil {
+		result.Plan.add(MigrationTable, migrationTableSql(mo.db), "迁移记录表不存在")
+	}
+	return map[string]map[int64]bool{}, nil
+}
+
+// migrate 对单张表依次执行未执行的迁移，失败即停止，dryRun 时只写入计划
+func (mo *MigrateOption) migrate(ctx context.Context, data *SchemaData, migrations []*Migration, applied map[int64]bool, result *MigrateResult) {
+	var pending = 0
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		pending++
+		if err := ctx.Err(); err != nil {
+			result.Failed[data.Table] = err
+			return
+		}
+		alterSql, err := executeTemplate(migration.tpl, data)
+		if err != nil {
+			result.Failed[data.Table] = fmt.Errorf("version %d 模板渲染失败：%w", migration.Version, err)
+			return
+		}
+		if mo.dryRun {
+			result.Plan.add(data.Table, alterSql, fmt.Sprintf("迁移 version %d %s", migration.Version, migration.Name))
+			continue
+		}
+		if _, err = mo.mysqlClient.ExecContext(ctx, alterSql); err != nil {
+			mo.getLogger().ErrorContext(ctx, "sharding.Migrate，迁移执行失败",
+				slog.String("table", data.Table), slog.Int64("version", migration.Version), slog.String("sql", alterSql), slog.Any("err", err))
+			result.Failed[data.Table] = fmt.Errorf("version %d 执行失败：%w", migration.Version, &DDLError{SQL: alterSql, Cause: err})
+			return
+		}
+		recordSql := fmt.Sprintf("INSERT IGNORE INTO %s.%s (`primary_table`, `table_name`, `version`, `name`) VALUES (?, ?, ?, ?)", quote(mo.db), quote(MigrationTable))
+		if _, err = mo.mysqlClient.ExecContext(ctx, recordSql, data.Primary, data.Table, migration.Version, migration.Name); err != nil {
+			result.Failed[data.Table] = fmt.Errorf("version %d 已执行，迁移记录写入失败：%w", migration.Version, err)
+			return
+		}
+		result.Applied[data.Table] = append(result.Applied[data.Table], migration.Version)
+		mo.getLogger().InfoContext(ctx, "sharding.Migrate，迁移执行成功",
+			slog.String("table", data.Table), slog.Int64("version", migration.Version), slog.String("sql", alterSql))
+		if mo.registry != nil {
+			if err = mo.registry.SetSchemaVersion(ctx, data.Table, migration.Version); err != nil {
+				mo.getLogger().WarnContext(ctx, "sharding.Migrate，登记表结构版本更新失败",
+					slog.String("table", data.Table), slog.Int64("version", migration.Version), slog.Any("err", err))
+			}
+		}
+	}
+	if pending == 0 {
+		result.Skipped++
+	}
+}
+
+// prepareMigrations 按版本号排序并解析 sql 模板
+func prepareMigrations(migrations []*Migration) ([]*Migration, error) {
+	var sorted = make([]*Migration, 0, len(migrations))
+	var versions = make(map[int64]bool)
+	for _, migration := range migrations {
+		if migration == nil || migration.Version <= 0 {
+			return nil, invalidOption("Migrations", "sharding.Migrate，迁移版本号必须大于0")
+		}
+		if versions[migration.Version] {
+			return nil, invalidOption("Migrations", fmt.Sprintf("sharding.Migrate，迁移版本号重复：%d", migration.Version))
+		}
+		versions[migration.Version] = true
+		if strings.TrimSpace(migration.SQL) == "" {
+			return nil, invalidOption("Migrations", fmt.Sprintf("sharding.Migrate，version %d sql 不能为空", migration.Version))
+		}
+		tpl, err := template.New(fmt.Sprintf("migration_%d", migration.Version)).Option("missingkey=error").Parse(migration.SQL)
+		if err != nil {
+			return nil, &ErrInvalidOption{Field: "Migrations", Msg: fmt.Sprintf("sharding.Migrate，version %d sql 模板解析失败：%v", migration.Version, err), Cause: err}
+		}
+		var copied = *migration
+		copied.tpl = tpl
+		sorted = append(sorted, &copied)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return sorted, nil
+}
+
+// ensureMigrationTable 创建迁移记录表
+func ensureMigrationTable(ctx context.Context, client *sql.DB, db string) error {
+	if _, err := client.ExecContext(ctx, migrationTableSql(db)); err != nil {
+		return fmt.Errorf("sharding.Migrate，迁移记录表创建失败：%w", err)
+	}
+	return nil
+}
+
+// migrationTableSql 迁移记录表建表语句
+func migrationTableSql(db string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s ("+
+		"`primary_table` VARCHAR(64) NOT NULL COMMENT '基础表名',"+
+		"`table_name` VARCHAR(64) NOT NULL COMMENT '表名，基础表或分表',"+
+		"`version` BIGINT NOT NULL COMMENT '迁移版本号',"+
+		"`name` VARCHAR(255) NOT NULL DEFAULT '' COMMENT '迁移描述',"+
+		"`applied_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '执行时间',"+
+		"PRIMARY KEY (`table_name`, `version`),"+
+		"KEY `idx_primary_table` (`primary_table`)"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分表迁移记录'", quote(db), quote(MigrationTable))
+}
+
+// appliedVersions 基础表 primary 及其分表已执行的版本，key 为表名
+func appliedVersions(ctx context.Context, client *sql.DB, db, primary string) (map[string]map[int64]bool, error) {
+	query := fmt.Sprintf("SELECT `table_name`, `version` FROM %s.%s WHERE `primary_table` = ?", quote(db), quote(MigrationTable))
+	var applied = make(map[string]map[int64]bool)
+	err := queryEach(ctx, client, query, []any{primary}, func(rows *sql.Rows) error {
+		var table string
+		var version int64
+		if err := rows.Scan(&table, &version); err != nil {
+			return err
+		}
+		if applied[table] == nil {
+			applied[table] = make(map[int64]bool)
+		}
+		applied[table][version] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sharding.Migrate，迁移记录查询失败：%w", err)
+	}
+	return applied, nil
+}
+
+// inheritMigrations 新建分表继承基础表的迁移记录，未使用迁移（记录表不存在）时忽略
+func inheritMigrations(ctx context.Context, client *sql.DB, db, primary, table string) error {
+	inheritSql := fmt.Sprintf("INSERT IGNORE INTO %[1]s.%[2]s (`primary_table`, `table_name`, `version`, `name`) "+
+		"SELECT `primary_table`, ?, `version`, `name` FROM %[1]s.%[2]s WHERE `primary_table` = ? AND `table_name` = ?", quote(db), quote(MigrationTable))
+	_, err := client.ExecContext(ctx, inheritSql, table, primary, primary)
+	if isNoSuchTable(err) {
+		return nil
+	}
+	return err
+}
+
+// recordMigrations 按建表模板新建的分表已包含 migrations 的结构，直接写入迁移记录，Migrate 不再重复执行
+func recordMigrations(ctx context.Context, client *sql.DB, db, primary, table string, migrations []*Migration) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+	if err := ensureMigrationTable(ctx, client, db); err != nil {
+		return err
+	}
+	recordSql := fmt.Sprintf("INSERT IGNORE INTO %s.%s (`primary_table`, `table_name`, `version`, `name`) VALUES (?, ?, ?, ?)"+
+		strings.Repeat(", (?, ?, ?, ?)", len(migrations)-1), quote(db), quote(MigrationTable))
+	var args = make([]any, 0, len(migrations)*4)
+	for _, migration := range migrations {
+		args = append(args, primary, table, migration.Version, migration.Name)
+	}
+	_, err := client.ExecContext(ctx, recordSql, args...)
+	return err
+}
+
+// isNoSuchTable mysql 表不存在错误
+func isNoSuchTable(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errNoSuchTable
+}
+
+// tableExists 表是否存在
+func tableExists(ctx context.Context, client *sql.DB, db, table string) (bool, error) {
+	var count int
+	err := client.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", db, table).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("sharding，表信息查询失败：%w", err)
+	}
+	return count > 0, nil
+}
+
+// MigrateOption 迁移参数，由 MigrateBuilder 传入
+type MigrateOption struct {
+	// 数据库连接
+	mysqlClient *sql.DB
+	// 库名
+	db string
+	// 基础表名
+	primary string
+	// 迁移列表
+	migrations []*Migration
+	// 并发执行的分表数量，默认4
+	concurrency int
+	// 分表登记表，设置后同步更新结构版本
+	registry *Registry
+	// 日志，默认 slog.Default()
+	logger *slog.Logger
+	// 只计算执行计划，不执行
+	dryRun bool
+}
+
+// getLogger 日志默认使用 slog.Default()，统一带上库名、基础表名
+func (mo *MigrateOption) getLogger() *slog.Logger {
+	var logger = mo.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With(slog.String("db", mo.db), slog.String("primary", mo.primary))
+}
+
+type MigrateOptionsBuilder struct {
+	funcs []MigrateOptionFunc
+}
+
+func MigrateBuilder() *MigrateOptionsBuilder {
+	return &MigrateOptionsBuilder{}
+}
+
+type MigrateOptionFunc func(*MigrateOption)
+
+func (mb *MigrateOptionsBuilder) MysqlClient(mysqlClient *sql.DB) *MigrateOptionsBuilder {
+	mb.funcs = append(mb.funcs, func(opt *MigrateOption) {
+		opt.mysqlClient = mysqlClient
+	})
+	return mb
+}
+
+func (mb *MigrateOptionsBuilder) DBName(dbName string) *MigrateOptionsBuilder {
+	mb.funcs = append(mb.funcs, func(opt *MigrateOption) {
+		opt.db = dbName
+	})
+	return mb
+}
+
+func (mb *MigrateOptionsBuilder) Primary(primary string) *MigrateOptionsBuilder {
+	mb.funcs = append(mb.funcs, func(opt *MigrateOption) {
+		opt.primary = primary
+	})
+	return mb
+}
+
+// Migrations 追加迁移，执行顺序以版本号为准
+func (mb *MigrateOptionsBuilder) Migrations(migrations ...*Migration) *MigrateOptionsBuilder {
+	mb.funcs = append(mb.funcs, func(opt *MigrateOption) {
+		opt.migrations = append(opt.migrations, migrations...)
+	})
+	return mb
+}
+
+// Concurrency 并发执行的分表数量，默认4
+func (mb *MigrateOptionsBuilder) Concurrency(n int) *MigrateOptionsBuilder {
+	mb.funcs = append(mb.funcs, func(opt *MigrateOption) {
+		opt.concurrency = n
+	})
+	return mb
+}
+
+// Registry 迁移成功后同步更新分表登记表中的结构版本
+func (mb *MigrateOptionsBuilder) Registry(registry *Registry) *MigrateOptionsBuilder {
+	mb.funcs = append(mb.funcs, func(opt *MigrateOption) {
+		opt.registry = registry
+	})
+	return mb
+}
+
+// DryRun 只计算将要执行的迁移语句，写入 MigrateResult.Plan，不执行、不写迁移记录
+func (mb *MigrateOptionsBuilder) DryRun() *MigrateOptionsBuilder {
+	mb.funcs = append(mb.funcs, func(opt *MigrateOption) {
+		opt.dryRun = true
+	})
+	return mb
+}
+
+// Logger 设置日志，默认 slog.Default()
+func (mb *MigrateOptionsBuilder) Logger(logger *slog.Logger) *MigrateOptionsBuilder {
+	mb.funcs = append(mb.funcs, func(opt *MigrateOption) {
+		opt.logger = logger
+	})
+	return mb
+}
@@ -0,0 +1,33 @@
+package sharding
+
+import (
+	"context"
+	"time"
+)
+
+// Observer 分表观测回调，用于接入监控，回调在调用方协程内同步执行，实现需要并发安全且尽量轻量
+// 只关心部分事件时可以嵌入 NopObserver
+type Observer interface {
+	// CacheHit 分表存在性缓存命中
+	CacheHit(ctx context.Context, db, primary, table string)
+	// CacheMiss 分表存在性缓存未命中，随后加锁检查建表
+	CacheMiss(ctx context.Context, db, primary, table string)
+	// LockAcquired 建表分布式锁获取成功，wait 为等待时长，attempts 为尝试次数
+	LockAcquired(ctx context.Context, db, primary string, wait time.Duration, attempts int)
+	// LockFailed 建表分布式锁获取失败
+	LockFailed(ctx context.Context, db, primary string, wait time.Duration, attempts int)
+	// DDLExecuted 建表语句执行完成，err 不为空表示执行失败
+	DDLExecuted(ctx context.Context, db, primary, table string, duration time.Duration, err error)
+	// ParamsSplit Params 拆分查询区间完成，buckets 为拆分出的分表数，ctx 为 ParamsContext 传入的 ctx
+	ParamsSplit(ctx context.Context, primary string, t Type, buckets int)
+}
+
+// NopObserver 空实现
+type NopObserver struct{}
+
+func (NopObserver) CacheHit(context.Context, string, string, string)                          {}
+func (NopObserver) CacheMiss(context.Context, string, string, string)                         {}
+func (NopObserver) LockAcquired(context.Context, string, string, time.Duration, int)          {}
+func (NopObserver) LockFailed(context.Context, string, string, time.Duration, int)            {}
+func (NopObserver) DDLExecuted(context.Context, string, string, string, time.Duration, error) {}
+func (NopObserver) ParamsSplit(context.Context, string, Type, int)                            {}
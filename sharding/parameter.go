@@ -1,7 +1,7 @@
 package sharding
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"github.com/line-lee/toolkit/beankit"
 	"time"
@@ -12,41 +12,170 @@ type ParamsResult struct {
 	Start      time.Time
 	End        time.Time
 	IsEndClose bool // 是否闭合，false就是<end，true就使用<=end
+	// 组合分表时的 key 后缀和落在该分表的 key，只按时间分表时为空
+	Shard string
+	Keys  []int64
+	// 分表所在的数据库，设置 Placement 时有值
+	Target *Target
+	// 分表类型，分层分表时每段不同
+	Type Type
+	// 时间列为 unix 时间戳时的单位，设置 Unix 时有值，StartUnix、EndUnix 为对应的时间戳，见 Condition
+	Unit      UnixUnit
+	StartUnix int64
+	EndUnix   int64
+}
+
+// Condition 时间列 column 在该分表查询范围内的 WHERE 条件和参数，例如 "`created_at` >= ? AND `created_at` < ?"，
+// 设置 Unix 时参数为时间戳，否则为 time.Time
+func (p *ParamsResult) Condition(column string) (string, []any) {
+	return rangeCondition(column, p.Unit, p.Start, p.End, p.IsEndClose)
 }
 
 func Params(builder *ParamsOptionsBuilder) ([]*ParamsResult, error) {
+	return ParamsContext(context.Background(), builder)
+}
+
+// ParamsContext 同 Params，ctx 传给 Observer 的 ParamsSplit 回调
+func ParamsContext(ctx context.Context, builder *ParamsOptionsBuilder) ([]*ParamsResult, error) {
 	option := new(ParamsOption)
 	for _, opf := range builder.funcs {
 		opf(option)
 	}
+	if err := validateUnit(option.unit); err != nil {
+		return nil, err
+	}
 	if beankit.IsStringBlank(option.primary) {
-		return nil, errors.New("primary option is required，使用 WithParamsPrimary 传入option参数")
+		return nil, invalidOption("Primary", "primary option is required，使用 WithParamsPrimary 传入option参数")
 	}
 	if option.start.IsZero() {
-		return nil, errors.New("start option is required，使用 WithParamsStart 传入option参数")
+		return nil, invalidOption("Start", "start option is required，使用 WithParamsStart 传入option参数")
 	}
 	if option.end.IsZero() {
-		return nil, errors.New("end option is required，使用 WithParamsEnd 传入option参数")
+		return nil, invalidOption("End", "end option is required，使用 WithParamsEnd 传入option参数")
 	}
 	if option.end.Before(option.start) {
-		return nil, errors.New("WARNING:star > end")
+		return nil, invalidOption("End", "WARNING:star > end")
+	}
+	if len(option.schedule) > 0 {
+		if len(option.tiers) > 0 {
+			return nil, invalidOption("Schedule", "sharding，option Schedule 不能与 Tiers 同时使用")
+		}
+		if err := validateSchedule(option.schedule); err != nil {
+			return nil, err
+		}
+	} else if len(option.tiers) > 0 {
+		if err := validateTiers(option.tiers); err != nil {
+			return nil, err
+		}
+		if option.now.IsZero() {
+			option.now = time.Now()
+		}
+	} else if option.t == 0 {
+		return nil, invalidOption("Type", "t option is required，使用 WithParamsType 传入option参数")
+	}
+	result, err := option.split()
+	if err != nil {
+		return nil, err
+	}
+	if option.keyStrategy != nil {
+		// 组合分表：每个 key 后缀分别按时间拆分，结果为 key 后缀 × 时间分表
+		routes, err := routeKeys(option.primary, option.keyStrategy, option.keys)
+		if err != nil {
+			return nil, err
+		}
+		if len(routes) == 0 {
+			return nil, invalidOption("Keys", "keys option is required，分表策略没有固定分表时需要传入 key")
+		}
+		result = make([]*ParamsResult, 0, len(routes)*len(result))
+		for _, route := range routes {
+			var keyed = *option
+			keyed.primary, keyed.shard = route.TableName, route.Shard
+			split, _ := keyed.split()
+			for _, param := range split {
+				param.Shard, param.Keys = route.Shard, route.Keys
+			}
+			result = append(result, split...)
+		}
 	}
-	if option.t == 0 {
-		return nil, errors.New("t option is required，使用 WithParamsType 传入option参数")
+	if option.placement != nil {
+		for _, param := range result {
+			var start, _ = param.Type.Bucket(param.Start)
+			if param.Target, err = option.placement.Resolve(param.Shard, start); err != nil {
+				return nil, err
+			}
+		}
 	}
-	switch option.t {
+	if option.unit != 0 {
+		for _, param := range result {
+			param.Unit = option.unit
+			_, args := param.Condition("")
+			param.StartUnix, param.EndUnix = args[0].(int64), args[1].(int64)
+		}
+	}
+	if option.observer != nil {
+		var t = option.t
+		if len(option.tiers) > 0 {
+			t = option.tiers[0].Type
+		} else if len(option.schedule) > 0 {
+			t = cutoverAt(option.schedule, option.end).Type
+		}
+		option.observer.ParamsSplit(ctx, option.primary, t, len(result))
+	}
+	return result, nil
+}
+
+// split 按分表类型拆分时间范围，分层分表、粒度调整时先切段，每段按该段的分表类型拆分
+func (po *ParamsOption) split() ([]*ParamsResult, error) {
+	if len(po.schedule) > 0 {
+		return po.splitSegments(scheduleSegments(po.schedule, po.start, po.end, po.isEndClose))
+	}
+	if len(po.tiers) > 0 {
+		return po.splitSegments(tierSegments(po.tiers, po.now, po.start, po.end, po.isEndClose))
+	}
+	return po.splitType()
+}
+
+// splitSegments 依次拆分每一段
+func (po *ParamsOption) splitSegments(segments []*segment) ([]*ParamsResult, error) {
+	var result = make([]*ParamsResult, 0)
+	for _, seg := range segments {
+		var part = *po
+		part.start, part.end, part.isEndClose, part.t = seg.start, seg.end, seg.isEndClose, seg.t
+		if seg.primary != "" {
+			// 组合分表时保留 key 后缀
+			part.primary = seg.primary
+			if po.shard != "" {
+				part.primary += "_" + po.shard
+			}
+		}
+		split, err := part.splitType()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, split...)
+	}
+	return result, nil
+}
+
+// splitType 按单一分表类型拆分
+func (po *ParamsOption) splitType() ([]*ParamsResult, error) {
+	var result []*ParamsResult
+	switch po.t {
 	case Hour:
-		return option.hour(), nil
+		result = po.hour()
 	case Day:
-		return option.day(), nil
+		result = po.day()
 	case Month:
-		return option.month(), nil
+		result = po.month()
 	case Year:
-		return option.year(), nil
+		result = po.year()
 	default:
-		return nil, errors.New("WARNING：type unknown")
+		return nil, fmt.Errorf("WARNING：type unknown：%w", ErrUnknownType)
 	}
-
+	for _, param := range result {
+		param.Type = po.t
+	}
+	return result, nil
 }
 
 // ParamsOption 所有参数，由option方法传入，比如primary，由 WithParamsPrimary() 写入参数
@@ -61,6 +190,22 @@ type ParamsOption struct {
 	isEndClose bool
 	// 分表类型，传入定义枚举，
 	t Type
+	// 观测回调
+	observer Observer
+	// 组合分表的 key 策略和查询的 key
+	keyStrategy KeyStrategy
+	keys        []int64
+	// 分表放置规则
+	placement *Placement
+	// 分层分表，设置后不需要 Type，now 为计算分层的当前时间
+	tiers []Tier
+	now   time.Time
+	// 分表粒度调整计划，设置后不需要 Type
+	schedule []Cutover
+	// 组合分表展开时当前的 key 后缀
+	shard string
+	// 时间列为 unix 时间戳时的单位
+	unit UnixUnit
 }
 
 type ParamsOptionsBuilder struct {
@@ -108,6 +253,75 @@ func (pb *ParamsOptionsBuilder) Type(t Type) *ParamsOptionsBuilder {
 	return pb
 }
 
+// Keys 组合分表，按 strategy 把 keys 映射到 key 后缀，结果为 key 后缀 × 时间分表，
+// 例如 Identity{Prefix: "t"}、keys 为 42、43 按月查询 8~9 月时为 orders_t42_202508、orders_t42_202509、orders_t43_202508、orders_t43_202509
+// keys 为空时使用策略的所有分表
+func (pb *ParamsOptionsBuilder) Keys(strategy KeyStrategy, keys ...int64) *ParamsOptionsBuilder {
+	pb.funcs = append(pb.funcs, func(option *ParamsOption) {
+		option.keyStrategy = strategy
+		option.keys = append(option.keys, keys...)
+	})
+	return pb
+}
+
+// Placement 按放置规则为每张分表填充 ParamsResult.Target，查询时使用对应的连接
+func (pb *ParamsOptionsBuilder) Placement(placement *Placement) *ParamsOptionsBuilder {
+	pb.funcs = append(pb.funcs, func(option *ParamsOption) {
+		option.placement = placement
+	})
+	return pb
+}
+
+// Tiers 分层分表，按分表距今的时间选择分表类型，与 Compact 使用相同的分层，设置后不需要 Type，
+// 粗一层的分表由 Compact 在分界到达前提前复制，见 CompactBuilder().Ahead()
+func (pb *ParamsOptionsBuilder) Tiers(tiers ...Tier) *ParamsOptionsBuilder {
+	pb.funcs = append(pb.funcs, func(option *ParamsOption) {
+		option.tiers = tiers
+	})
+	return pb
+}
+
+// Schedule 分表粒度调整计划，跨调整时间的查询按每段的分表类型拆分，设置后不需要 Type
+func (pb *ParamsOptionsBuilder) Schedule(schedule ...Cutover) *ParamsOptionsBuilder {
+	pb.funcs = append(pb.funcs, func(option *ParamsOption) {
+		option.schedule = schedule
+	})
+	return pb
+}
+
+// Now 分层分表计算层级的当前时间，默认 time.Now()
+func (pb *ParamsOptionsBuilder) Now(now time.Time) *ParamsOptionsBuilder {
+	pb.funcs = append(pb.funcs, func(option *ParamsOption) {
+		option.now = now
+	})
+	return pb
+}
+
+// Unix 时间列为 unix 时间戳，结果中填充 StartUnix、EndUnix，Condition 的参数使用时间戳
+func (pb *ParamsOptionsBuilder) Unix(unit UnixUnit) *ParamsOptionsBuilder {
+	pb.funcs = append(pb.funcs, func(option *ParamsOption) {
+		option.unit = unit
+	})
+	return pb
+}
+
+// UnixRange 以时间戳传入查询范围，loc 为分表名使用的时区，为 nil 时使用 time.Local，同时设置 Unix(unit)
+func (pb *ParamsOptionsBuilder) UnixRange(start, end int64, unit UnixUnit, loc *time.Location) *ParamsOptionsBuilder {
+	pb.funcs = append(pb.funcs, func(option *ParamsOption) {
+		option.start, option.end = unit.Time(start, loc), unit.Time(end, loc)
+		option.unit = unit
+	})
+	return pb
+}
+
+// Observer 设置观测回调，拆分完成后回调 ParamsSplit，使用 ParamsContext 时回调带上调用方的 ctx
+func (pb *ParamsOptionsBuilder) Observer(observer Observer) *ParamsOptionsBuilder {
+	pb.funcs = append(pb.funcs, func(option *ParamsOption) {
+		option.observer = observer
+	})
+	return pb
+}
+
 func (po *ParamsOption) hour() []*ParamsResult {
 	const timeFormat = "2006010215"
 	var result = make([]*ParamsResult, 0)
@@ -0,0 +1,90 @@
+package sharding
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Target 分表所在的数据库
+type Target struct {
+	// 名称，例如 cluster_2025
+	Name string
+	// 数据库连接
+	Client *sql.DB
+	// 库名
+	DB string
+}
+
+// placementRule 放置规则，match 为 true 时分表放在 target
+type placementRule struct {
+	target string
+	match  func(shard string, start time.Time) bool
+}
+
+// Placement 分表放置规则，按分表时间、key 后缀把分表映射到不同的数据库实例和库，按添加顺序匹配，都不匹配时使用 Default
+type Placement struct {
+	targets  map[string]*Target
+	rules    []*placementRule
+	fallback string
+}
+
+// NewPlacement 分表放置规则
+func NewPlacement() *Placement {
+	return &Placement{targets: make(map[string]*Target)}
+}
+
+// Target 添加数据库，name 用于规则引用
+func (p *Placement) Target(name string, client *sql.DB, db string) *Placement {
+	p.targets[name] = &Target{Name: name, Client: client, DB: db}
+	return p
+}
+
+// Range 开始时间在 [start, end) 内的分表放在 target，例如每年一个实例：Range(2025-01-01, 2026-01-01, "cluster_2025")
+func (p *Placement) Range(start, end time.Time, target string) *Placement {
+	return p.Rule(target, func(_ string, bucket time.Time) bool {
+		return !bucket.Before(start) && bucket.Before(end)
+	})
+}
+
+// Shards key 后缀为 shards 之一的分表放在 target，例如租户分组：Shards("cluster_a", "t1", "t2")
+func (p *Placement) Shards(target string, shards ...string) *Placement {
+	var set = make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		set[shard] = true
+	}
+	return p.Rule(target, func(shard string, _ time.Time) bool {
+		return set[shard]
+	})
+}
+
+// Rule 自定义规则，shard 为 key 后缀（只按时间分表时为空），start 为分表开始时间（只按 key 分表时为零值）
+func (p *Placement) Rule(target string, match func(shard string, start time.Time) bool) *Placement {
+	p.rules = append(p.rules, &placementRule{target: target, match: match})
+	return p
+}
+
+// Default 都不匹配时使用的数据库
+func (p *Placement) Default(target string) *Placement {
+	p.fallback = target
+	return p
+}
+
+// Resolve 分表所在的数据库，shard 为 key 后缀，start 为分表开始时间
+func (p *Placement) Resolve(shard string, start time.Time) (*Target, error) {
+	var name = p.fallback
+	for _, rule := range p.rules {
+		if rule.match(shard, start) {
+			name = rule.target
+			break
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("sharding.Placement，shard %q start %s：%w", shard, start.Format(time.DateTime), ErrNoPlacement)
+	}
+	target, ok := p.targets[name]
+	if !ok || target.Client == nil || target.DB == "" {
+		return nil, invalidOption("Placement", fmt.Sprintf("sharding.Placement，数据库 %s 未配置", name))
+	}
+	return target, nil
+}
@@ -0,0 +1,60 @@
+package sharding
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Statement 执行计划中的一条语句
+type Statement struct {
+	// 目标表
+	Table string `json:"table"`
+	// 将要执行的语句
+	SQL string `json:"sql"`
+	// 执行原因
+	Reason string `json:"reason"`
+}
+
+// Plan 执行计划，dry-run 时只计算将要执行的 CREATE、DROP、ALTER 语句，不执行，用于 DBA 审核
+type Plan struct {
+	Statements []*Statement `json:"statements"`
+}
+
+// add 追加一条语句
+func (p *Plan) add(table, sql, reason string) {
+	p.Statements = append(p.Statements, &Statement{Table: table, SQL: sql, Reason: reason})
+}
+
+// Merge 依次追加其他计划的语句
+func (p *Plan) Merge(plans ...*Plan) *Plan {
+	for _, plan := range plans {
+		if plan != nil {
+			p.Statements = append(p.Statements, plan.Statements...)
+		}
+	}
+	return p
+}
+
+// Empty 没有需要执行的语句
+func (p *Plan) Empty() bool {
+	return p == nil || len(p.Statements) == 0
+}
+
+// WriteTo 输出审核文件，每条语句前用注释写明目标表和原因，语句以分号结尾，可以直接交给 mysql 客户端执行
+func (p *Plan) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "-- sharding plan，共 %d 条语句\n", len(p.Statements))
+	for _, statement := range p.Statements {
+		fmt.Fprintf(&buf, "\n-- %s：%s\n%s;\n", statement.Table, statement.Reason, strings.TrimRight(strings.TrimSpace(statement.SQL), ";"))
+	}
+	return buf.WriteTo(w)
+}
+
+// String 审核文件内容
+func (p *Plan) String() string {
+	var sb strings.Builder
+	_, _ = p.WriteTo(&sb)
+	return sb.String()
+}
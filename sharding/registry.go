@@ -0,0 +1,271 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RegistryTable 分表登记表，记录每张分表的生命周期
+const RegistryTable = "_sharding_registry"
+
+// ShardStatus 分表状态
+type ShardStatus string
+
+const (
+	StatusActive   ShardStatus = "active"   // 使用中
+	StatusArchived ShardStatus = "archived" // 已归档，数据已转存，表仍保留
+	StatusDropped  ShardStatus = "dropped"  // 已删除
+)
+
+// RegistryEntry 分表登记信息
+type RegistryEntry struct {
+	// 基础表名
+	Primary string `json:"primary"`
+	// 分表名
+	Table string `json:"table"`
+	// 分表时间范围，左闭右开
+	BucketStart time.Time `json:"bucket_start"`
+	BucketEnd   time.Time `json:"bucket_end"`
+	// 分表状态
+	Status ShardStatus `json:"status"`
+	// 分表结构版本，即已执行的最大迁移版本号
+	SchemaVersion int64 `json:"schema_version"`
+	// 登记时间、最近更新时间
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Registry 分表登记表，保存在库 db 的 RegistryTable 中，首次使用时自动建表
+type Registry struct {
+	mysqlClient *sql.DB
+	db          string
+	logger      *slog.Logger
+	cache       Cache
+
+	mu    sync.Mutex
+	ready bool
+}
+
+// NewRegistry 分表登记表，mysqlClient 为登记表所在的数据库连接，db 为库名
+func NewRegistry(mysqlClient *sql.DB, db string) *Registry {
+	return &Registry{mysqlClient: mysqlClient, db: db}
+}
+
+// SetLogger 设置日志，默认 slog.Default()
+func (r *Registry) SetLogger(logger *slog.Logger) *Registry {
+	r.logger = logger
+	return r
+}
+
+// SetCache 设置分表存在性缓存，Drop 后通过该缓存失效，默认 DefaultCache()，与 TableBuilder().Cache() 使用同一个缓存
+func (r *Registry) SetCache(c Cache) *Registry {
+	r.cache = c
+	return r
+}
+
+func (r *Registry) getCache() Cache {
+	if r.cache != nil {
+		return r.cache
+	}
+	return DefaultCache()
+}
+
+func (r *Registry) getLogger() *slog.Logger {
+	var logger = r.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With(slog.String("db", r.db))
+}
+
+// Init 创建登记表，其他方法首次调用时也会自动执行
+func (r *Registry) Init(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ready {
+		return nil
+	}
+	createSql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ("+
+		"`table_name` VARCHAR(64) NOT NULL COMMENT '分表名',"+
+		"`primary_table` VARCHAR(64) NOT NULL COMMENT '基础表名',"+
+		"`bucket_start` BIGINT NOT NULL COMMENT '分表开始时间，unix秒',"+
+		"`bucket_end` BIGINT NOT NULL COMMENT '分表结束时间，unix秒，不包含',"+
+		"`status` VARCHAR(16) NOT NULL COMMENT '状态：active，archived，dropped',"+
+		"`schema_version` BIGINT NOT NULL DEFAULT 0 COMMENT '结构版本',"+
+		"`created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '登记时间',"+
+		"`updated_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',"+
+		"PRIMARY KEY (`table_name`),"+
+		"KEY `idx_primary_bucket` (`primary_table`, `bucket_start`)"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分表登记'", r.table())
+	if _, err := r.mysqlClient.ExecContext(ctx, createSql); err != nil {
+		return fmt.Errorf("sharding.Registry，登记表创建失败：%w", err)
+	}
+	r.ready = true
+	return nil
+}
+
+// Register 登记一张使用中的分表，已登记的更新时间范围和结构版本，已归档的状态不会被改回使用中，
+// 已删除的分表重新建表后改回使用中
+func (r *Registry) Register(ctx context.Context, primary, table string, start, end time.Time) error {
+	if err := r.Init(ctx); err != nil {
+		return err
+	}
+	version, err := r.schemaVersion(ctx, table)
+	if err != nil {
+		return err
+	}
+	registerSql := fmt.Sprintf("INSERT INTO %s (`table_name`, `primary_table`, `bucket_start`, `bucket_end`, `status`, `schema_version`) "+
+		"VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE `bucket_start` = VALUES(`bucket_start`), `bucket_end` = VALUES(`bucket_end`), "+
+		"`status` = IF(`status` = 'archived', `status`, VALUES(`status`)), `schema_version` = VALUES(`schema_version`)", r.table())
+	if _, err = r.mysqlClient.ExecContext(ctx, registerSql, table, primary, start.Unix(), end.Unix(), StatusActive, version); err != nil {
+		return fmt.Errorf("sharding.Registry，分表登记失败：%w", err)
+	}
+	return nil
+}
+
+// Archive 标记分表已归档，只修改登记状态，数据转存由调用方完成
+func (r *Registry) Archive(ctx context.Context, primary, table string) error {
+	return r.setStatus(ctx, primary, table, StatusArchived)
+}
+
+// MarkDropped 标记分表已删除，只修改登记状态，删表由调用方通过分表所在的连接完成，
+// 用于登记表与分表不在同一个连接或库的情况
+func (r *Registry) MarkDropped(ctx context.Context, primary, table string) error {
+	return r.setStatus(ctx, primary, table, StatusDropped)
+}
+
+// Drop 删除登记表所在库 db 中的分表、清除缓存并标记为已删除，table 必须是 primary 的按时间分表，避免误删基础表；
+// 分表不在登记表所在的连接或库时，自行删表后调用 MarkDropped
+func (r *Registry) Drop(ctx context.Context, primary, table string) error {
+	plan, err := r.PlanDrop(primary, table)
+	if err != nil {
+		return err
+	}
+	if err = r.Init(ctx); err != nil {
+		return err
+	}
+	dropSql := plan.Statements[0].SQL
+	if _, err = r.mysqlClient.ExecContext(ctx, dropSql); err != nil {
+		r.getLogger().ErrorContext(ctx, "sharding.Registry，分表删除失败", slog.String("table", table), slog.String("sql", dropSql), slog.Any("err", err))
+		return &DDLError{SQL: dropSql, Cause: err}
+	}
+	r.getLogger().InfoContext(ctx, "sharding.Registry，分表已删除", slog.String("primary", primary), slog.String("table", table))
+	r.getCache().Invalidate(ctx, CacheKey(r.db, table))
+	return r.setStatus(ctx, primary, table, StatusDropped)
+}
+
+// PlanDrop 计算 Drop 将要执行的删表语句，不执行
+func (r *Registry) PlanDrop(primary, table string) (*Plan, error) {
+	if _, ok := ParseShard(primary, table, time.Local); !ok {
+		return nil, invalidOption("table", fmt.Sprintf("sharding.Registry，%s 不是 %s 的分表，拒绝删除", table, primary))
+	}
+	var plan = new(Plan)
+	plan.add(table, dropShardSql(r.db, table), fmt.Sprintf("删除 %s 的分表", primary))
+	return plan, nil
+}
+
+// SetSchemaVersion 更新分表结构版本，未登记的分表忽略
+func (r *Registry) SetSchemaVersion(ctx context.Context, table string, version int64) error {
+	if err := r.Init(ctx); err != nil {
+		return err
+	}
+	updateSql := fmt.Sprintf("UPDATE %s SET `schema_version` = ? WHERE `table_name` = ? AND `schema_version` < ?", r.table())
+	if _, err := r.mysqlClient.ExecContext(ctx, updateSql, version, table, version); err != nil {
+		return fmt.Errorf("sharding.Registry，结构版本更新失败：%w", err)
+	}
+	return nil
+}
+
+// Get 查询分表登记信息，未登记返回 sql.ErrNoRows
+func (r *Registry) Get(ctx context.Context, table string) (*RegistryEntry, error) {
+	if err := r.Init(ctx); err != nil {
+		return nil, err
+	}
+	return scanEntry(r.mysqlClient.QueryRowContext(ctx, r.selectSql()+" WHERE `table_name` = ?", table).Scan)
+}
+
+// List 查询基础表 primary 的分表登记信息，按分表时间排序，statuses 为空时返回所有状态
+func (r *Registry) List(ctx context.Context, primary string, statuses ...ShardStatus) ([]*RegistryEntry, error) {
+	if err := r.Init(ctx); err != nil {
+		return nil, err
+	}
+	var query = r.selectSql() + " WHERE `primary_table` = ?"
+	var args = []any{primary}
+	if len(statuses) > 0 {
+		query += " AND `status` IN (?" + strings.Repeat(", ?", len(statuses)-1) + ")"
+		for _, status := range statuses {
+			args = append(args, status)
+		}
+	}
+	query += " ORDER BY `bucket_start`, `table_name`"
+	var entries = make([]*RegistryEntry, 0)
+	err := queryEach(ctx, r.mysqlClient, query, args, func(rows *sql.Rows) error {
+		entry, err := scanEntry(rows.Scan)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sharding.Registry，登记信息查询失败：%w", err)
+	}
+	return entries, nil
+}
+
+// setStatus 修改分表状态，未登记的分表按表名解析时间范围后登记
+func (r *Registry) setStatus(ctx context.Context, primary, table string, status ShardStatus) error {
+	if err := r.Init(ctx); err != nil {
+		return err
+	}
+	var start, end int64
+	if shard, ok := ParseShard(primary, table, time.Local); ok {
+		start, end = shard.Start.Unix(), shard.End.Unix()
+	}
+	statusSql := fmt.Sprintf("INSERT INTO %s (`table_name`, `primary_table`, `bucket_start`, `bucket_end`, `status`) "+
+		"VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE `status` = VALUES(`status`)", r.table())
+	if _, err := r.mysqlClient.ExecContext(ctx, statusSql, table, primary, start, end, status); err != nil {
+		return fmt.Errorf("sharding.Registry，分表状态更新失败：%w", err)
+	}
+	return nil
+}
+
+// schemaVersion 分表已执行的最大迁移版本号，未使用迁移时为0
+func (r *Registry) schemaVersion(ctx context.Context, table string) (int64, error) {
+	versionSql := fmt.Sprintf("SELECT IFNULL(MAX(`version`), 0) FROM %s.%s WHERE `table_name` = ?", quote(r.db), quote(MigrationTable))
+	var version int64
+	err := r.mysqlClient.QueryRowContext(ctx, versionSql, table).Scan(&version)
+	if isNoSuchTable(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("sharding.Registry，结构版本查询失败：%w", err)
+	}
+	return version, nil
+}
+
+func (r *Registry) table() string {
+	return fmt.Sprintf("%s.%s", quote(r.db), quote(RegistryTable))
+}
+
+func (r *Registry) selectSql() string {
+	return "SELECT `primary_table`, `table_name`, `bucket_start`, `bucket_end`, `status`, `schema_version`, " +
+		"UNIX_TIMESTAMP(`created_at`), UNIX_TIMESTAMP(`updated_at`) FROM " + r.table()
+}
+
+// scanEntry 扫描一行登记信息，时间统一以 unix 秒读取，不依赖连接的 parseTime、loc 参数
+func scanEntry(scan func(dest ...any) error) (*RegistryEntry, error) {
+	var entry = new(RegistryEntry)
+	var start, end, created, updated int64
+	if err := scan(&entry.Primary, &entry.Table, &start, &end, &entry.Status, &entry.SchemaVersion, &created, &updated); err != nil {
+		return nil, err
+	}
+	entry.BucketStart, entry.BucketEnd = time.Unix(start, 0), time.Unix(end, 0)
+	entry.CreatedAt, entry.UpdatedAt = time.Unix(created, 0), time.Unix(updated, 0)
+	return entry, nil
+}
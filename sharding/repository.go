@@ -0,0 +1,480 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 每条 INSERT 默认写入的行数
+const defaultBatchSize = 500
+
+// Repository 按时间分表的通用读写，列由结构体 db 标签决定，分表时间由 shard:"time" 标签的字段决定：
+//
+//	type UserLog struct {
+//		ID        int64     `db:"id,pk,auto"`
+//		UserID    int64     `db:"user_id"`
+//		CreatedAt time.Time `db:"created_at" shard:"time"`
+//	}
+//
+// db 标签选项：pk 主键，Get 按该列查询，未标记时使用 id 列；auto 自增列，写入时忽略。
+// 时间列为 unix 时间戳时，分表时间字段为 int64，并通过 TableBuilder().Unix 设置单位
+type Repository[T any] struct {
+	option *RepositoryOption
+	meta   *structMeta
+	// 基础表配置，Primary、Type 等
+	base *TableOption
+}
+
+// NewRepository 分表读写对象，T 必须是结构体
+func NewRepository[T any](builder *RepositoryOptionsBuilder) (*Repository[T], error) {
+	option := new(RepositoryOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if option.table == nil {
+		return nil, invalidOption("Table", "sharding.NewRepository，option Table 必填")
+	}
+	if option.batchSize <= 0 {
+		option.batchSize = defaultBatchSize
+	}
+	if option.fanOut == nil {
+		option.fanOut = FanOutBuilder()
+	}
+	meta, err := loadStructMeta(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+	var base = new(TableOption)
+	for _, opf := range option.table.funcs {
+		opf(base)
+	}
+	if base.err != nil {
+		return nil, base.err
+	}
+	if option.cluster != nil {
+		base.mysqlClient = option.cluster.Writer()
+	}
+	if base.keyStrategy != nil {
+		return nil, invalidOption("Key", "sharding.NewRepository，只支持按时间分表")
+	}
+	if option.cluster != nil && base.placement != nil {
+		// 放置规则决定每张分表的数据库，Cluster 的主从连接不会生效
+		return nil, invalidOption("Cluster", "sharding.NewRepository，option Cluster 不能与 Placement 同时使用")
+	}
+	if err = validateUnit(base.unit); err != nil {
+		return nil, err
+	}
+	if meta.time.time == (base.unit != 0) {
+		return nil, invalidOption("Unix", fmt.Sprintf("sharding.NewRepository，分表时间字段 %s 与时间列单位不匹配，time.Time 对应 DATETIME 列，int64 对应 Unix 时间戳列", meta.time.name))
+	}
+	if len(base.schedule) > 0 {
+		if err = validateSchedule(base.schedule); err != nil {
+			return nil, err
+		}
+	} else if base.t.layout() == "" {
+		return nil, invalidOption("Type", "sharding.NewRepository，option Type 必填")
+	}
+	return &Repository[T]{option: option, meta: meta, base: base}, nil
+}
+
+// Insert 按分表时间写入，分表不存在时自动创建，同一张分表的数据按 BatchSize 合并为一条 INSERT
+func (r *Repository[T]) Insert(ctx context.Context, items ...T) error {
+	// 按分表名分组，tm 为组内第一条数据的时间，用于创建分表对象
+	type group struct {
+		tm   time.Time
+		rows []reflect.Value
+	}
+	var groups = make(map[string]*group)
+	var order = make([]string, 0)
+	for i := range items {
+		var value = reflect.ValueOf(&items[i]).Elem()
+		var tm = r.timeOf(value)
+		if tm.IsZero() {
+			return invalidOption("ThisTime", fmt.Sprintf("sharding.Repository.Insert，第 %d 条数据分表时间 %s 为空", i, r.meta.time.name))
+		}
+		var name = r.shardName(tm)
+		if _, ok := groups[name]; !ok {
+			groups[name] = &group{tm: tm}
+			order = append(order, name)
+		}
+		groups[name].rows = append(groups[name].rows, value)
+	}
+	var columns = make([]*structColumn, 0, len(r.meta.columns))
+	for _, column := range r.meta.columns {
+		if !column.auto {
+			columns = append(columns, column)
+		}
+	}
+	for _, name := range order {
+		var to = r.table(groups[name].tm)
+		var rows = groups[name].rows
+		for len(rows) > 0 {
+			var batch = rows[:min(len(rows), r.option.batchSize)]
+			rows = rows[len(batch):]
+			err := to.Do(ctx, func(table string) error {
+				query, args := insertSql(to.db, table, columns, batch)
+				_, err := to.mysqlClient.ExecContext(ctx, query, args...)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("sharding.Repository.Insert，%s：%w", to.expect, err)
+			}
+		}
+	}
+	return nil
+}
+
+// FindRange 查询 [start, end) 内的数据，跨分表时按分表时间顺序合并，
+// filter 为附加的 WHERE 条件，例如 "`user_id` = ?"，不存在的分表视为没有数据
+func (r *Repository[T]) FindRange(ctx context.Context, start, end time.Time, filter string, args ...any) ([]T, error) {
+	var builder = ParamsBuilder().Primary(r.base.primary).Start(start).End(end).Type(r.base.t).Schedule(r.base.schedule...).Unix(r.base.unit)
+	if r.base.placement != nil {
+		builder.Placement(r.base.placement)
+	}
+	params, err := Params(builder)
+	if err != nil {
+		return nil, err
+	}
+	var results = make([][]T, len(params))
+	var indexes = make(map[*ParamsResult]int, len(params))
+	for i, param := range params {
+		indexes[param] = i
+	}
+	err = ForEachShard(ctx, r.option.fanOut, params, func(ctx context.Context, param *ParamsResult) error {
+		client, db := r.reader(ctx, param)
+		var where, queryArgs = param.Condition(r.meta.time.name)
+		if strings.TrimSpace(filter) != "" {
+			where += " AND (" + filter + ")"
+			queryArgs = append(queryArgs, args...)
+		}
+		var query = fmt.Sprintf("SELECT %s FROM %s.%s WHERE %s", r.meta.selectList(), quote(db), quote(param.TableName), where)
+		items, err := r.query(ctx, client, query, queryArgs...)
+		if isNoSuchTable(err) {
+			return nil
+		}
+		results[indexes[param]] = items
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var items = make([]T, 0)
+	for _, result := range results {
+		items = append(items, result...)
+	}
+	return items, nil
+}
+
+// Get 按主键查询时间 tm 所在分表中的一条数据，没有数据或分表不存在时返回 sql.ErrNoRows
+func (r *Repository[T]) Get(ctx context.Context, id any, tm time.Time) (T, error) {
+	var zero T
+	if r.meta.pk == nil {
+		return zero, invalidOption("Repository", "sharding.Repository.Get，结构体没有主键列，使用 db:\"id,pk\" 标记")
+	}
+	var to = r.table(tm)
+	if to.err != nil {
+		return zero, to.err
+	}
+	var start, end = r.typeAt(tm).Bucket(tm)
+	client, db := r.reader(ctx, &ParamsResult{TableName: to.expect, Start: start, End: end, Target: to.target})
+	var query = fmt.Sprintf("SELECT %s FROM %s.%s WHERE %s = ? LIMIT 1", r.meta.selectList(), quote(db), quote(to.expect), quote(r.meta.pk.name))
+	items, err := r.query(ctx, client, query, id)
+	if err != nil && !isNoSuchTable(err) {
+		return zero, fmt.Errorf("sharding.Repository.Get，%s：%w", to.expect, err)
+	}
+	if len(items) == 0 {
+		return zero, fmt.Errorf("sharding.Repository.Get，%s id %v：%w", to.expect, id, sql.ErrNoRows)
+	}
+	return items[0], nil
+}
+
+// timeOf 数据的分表时间，int64 字段按时间列单位换算
+func (r *Repository[T]) timeOf(value reflect.Value) time.Time {
+	var field = value.FieldByIndex(r.meta.time.index)
+	if r.meta.time.time {
+		return field.Interface().(time.Time)
+	}
+	if field.Int() == 0 {
+		return time.Time{}
+	}
+	return r.base.unit.Time(field.Int(), r.base.loc)
+}
+
+// shardName 时间 tm 所在分表名，设置调整计划时按 tm 选择分表类型和分表名前缀
+func (r *Repository[T]) shardName(tm time.Time) string {
+	var primary, t = r.base.primary, r.base.t
+	if len(r.base.schedule) > 0 {
+		var cutover = cutoverAt(r.base.schedule, tm)
+		t = cutover.Type
+		if cutover.Primary != "" {
+			primary = cutover.Primary
+		}
+	}
+	return fmt.Sprintf("%s_%s", primary, tm.Format(t.layout()))
+}
+
+// typeAt 时间 tm 使用的分表类型
+func (r *Repository[T]) typeAt(tm time.Time) Type {
+	if len(r.base.schedule) > 0 {
+		return cutoverAt(r.base.schedule, tm).Type
+	}
+	return r.base.t
+}
+
+// table 时间 tm 所在分表
+func (r *Repository[T]) table(tm time.Time) *TableOption {
+	var funcs = make([]TableOptionFunc, 0, len(r.option.table.funcs)+2)
+	funcs = append(funcs, r.option.table.funcs...)
+	if r.option.cluster != nil {
+		funcs = append(funcs, func(opt *TableOption) { opt.mysqlClient = r.option.cluster.Writer() })
+	}
+	funcs = append(funcs, func(opt *TableOption) { opt.thisTime = tm })
+	return New(&TableOptionsBuilder{funcs: funcs})
+}
+
+// reader 查询分表使用的连接和库名：放置规则的目标库，设置 Cluster 时按策略选择，否则为 MysqlClient
+func (r *Repository[T]) reader(ctx context.Context, param *ParamsResult) (*sql.DB, string) {
+	if param.Target != nil {
+		return param.Target.Client, param.Target.DB
+	}
+	if r.option.cluster != nil {
+		return r.option.cluster.Client(ctx, param), r.base.db
+	}
+	return r.base.mysqlClient, r.base.db
+}
+
+// query 执行查询并按列顺序扫描到 T
+func (r *Repository[T]) query(ctx context.Context, client *sql.DB, query string, args ...any) ([]T, error) {
+	rows, err := client.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items = make([]T, 0)
+	for rows.Next() {
+		var item T
+		var value = reflect.ValueOf(&item).Elem()
+		var dest = make([]any, len(r.meta.columns))
+		for i, column := range r.meta.columns {
+			dest[i] = value.FieldByIndex(column.index).Addr().Interface()
+			if column.time {
+				dest[i] = &timeScanner{dst: dest[i].(*time.Time)}
+			}
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// insertSql 多行 INSERT 语句
+func insertSql(db, table string, columns []*structColumn, rows []reflect.Value) (string, []any) {
+	var names = make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quote(column.name)
+	}
+	var placeholder = "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+	var values = make([]string, len(rows))
+	var args = make([]any, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		values[i] = placeholder
+		for _, column := range columns {
+			args = append(args, row.FieldByIndex(column.index).Interface())
+		}
+	}
+	return fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES %s", quote(db), quote(table), strings.Join(names, ","), strings.Join(values, ",")), args
+}
+
+// timeScanner 扫描 time.Time 字段，连接未设置 parseTime 时 DATETIME 返回字符串，按驱动默认的 UTC 解析
+type timeScanner struct {
+	dst *time.Time
+}
+
+func (ts *timeScanner) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*ts.dst = time.Time{}
+	case time.Time:
+		*ts.dst = value
+	case []byte:
+		return ts.parse(string(value))
+	case string:
+		return ts.parse(value)
+	default:
+		return fmt.Errorf("sharding.Repository，%T 不能转换为 time.Time", src)
+	}
+	return nil
+}
+
+func (ts *timeScanner) parse(value string) error {
+	tm, err := time.ParseInLocation("2006-01-02 15:04:05.999999", value, time.UTC)
+	if err != nil {
+		if tm, err = time.ParseInLocation(time.DateOnly, value, time.UTC); err != nil {
+			return fmt.Errorf("sharding.Repository，时间 %q 解析失败：%w", value, err)
+		}
+	}
+	*ts.dst = tm
+	return nil
+}
+
+// structColumn 结构体字段对应的列
+type structColumn struct {
+	name  string
+	index []int
+	pk    bool
+	auto  bool
+	// time.Time 字段，扫描时兼容未设置 parseTime 的连接
+	time bool
+}
+
+// structMeta 结构体的列信息，按类型缓存
+type structMeta struct {
+	columns []*structColumn
+	// 分表时间列
+	time *structColumn
+	// 主键列
+	pk *structColumn
+}
+
+var structMetas sync.Map
+
+func (sm *structMeta) selectList() string {
+	var names = make([]string, len(sm.columns))
+	for i, column := range sm.columns {
+		names[i] = quote(column.name)
+	}
+	return strings.Join(names, ",")
+}
+
+// loadStructMeta 解析结构体标签，结果按类型缓存
+func loadStructMeta(typ reflect.Type) (*structMeta, error) {
+	if value, ok := structMetas.Load(typ); ok {
+		return value.(*structMeta), nil
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, invalidOption("Repository", fmt.Sprintf("sharding.NewRepository，%s 不是结构体", typ))
+	}
+	var meta = new(structMeta)
+	if err := meta.parse(typ, nil); err != nil {
+		return nil, err
+	}
+	if len(meta.columns) == 0 {
+		return nil, invalidOption("Repository", fmt.Sprintf("sharding.NewRepository，%s 没有 db 标签", typ))
+	}
+	if meta.time == nil {
+		return nil, invalidOption("Repository", fmt.Sprintf("sharding.NewRepository，%s 没有 shard:\"time\" 标签", typ))
+	}
+	if meta.pk == nil {
+		for _, column := range meta.columns {
+			if column.name == "id" {
+				meta.pk = column
+			}
+		}
+	}
+	value, _ := structMetas.LoadOrStore(typ, meta)
+	return value.(*structMeta), nil
+}
+
+// parse 解析字段，未打 db 标签的嵌入结构体展开
+func (sm *structMeta) parse(typ reflect.Type, parent []int) error {
+	var timeType = reflect.TypeFor[time.Time]()
+	for i := range typ.NumField() {
+		var field = typ.Field(i)
+		var index = append(append([]int{}, parent...), i)
+		tag, tagged := field.Tag.Lookup("db")
+		if !tagged && field.Anonymous && field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			if err := sm.parse(field.Type, index); err != nil {
+				return err
+			}
+			continue
+		}
+		if !tagged || tag == "-" || !field.IsExported() {
+			continue
+		}
+		var parts = strings.Split(tag, ",")
+		var column = &structColumn{name: parts[0], index: index, time: field.Type == timeType}
+		if column.name == "" {
+			return invalidOption("Repository", fmt.Sprintf("sharding.NewRepository，字段 %s db 标签缺少列名", field.Name))
+		}
+		for _, part := range parts[1:] {
+			switch part {
+			case "pk":
+				column.pk = true
+				sm.pk = column
+			case "auto":
+				column.auto = true
+			default:
+				return invalidOption("Repository", fmt.Sprintf("sharding.NewRepository，字段 %s db 标签选项 %s 不识别", field.Name, part))
+			}
+		}
+		if field.Tag.Get("shard") == "time" {
+			if field.Type != timeType && field.Type.Kind() != reflect.Int64 {
+				return invalidOption("Repository", fmt.Sprintf("sharding.NewRepository，字段 %s 分表时间必须是 time.Time 或 int64", field.Name))
+			}
+			sm.time = column
+		}
+		sm.columns = append(sm.columns, column)
+	}
+	return nil
+}
+
+// RepositoryOption 分表读写参数，由 RepositoryBuilder 传入
+type RepositoryOption struct {
+	// 分表配置，Primary、Type、连接等，不需要 ThisTime
+	table *TableOptionsBuilder
+	// 读写分离，写入使用主库，查询按策略选择从库
+	cluster *Cluster
+	// 每条 INSERT 最多写入的行数，默认 500
+	batchSize int
+	// 跨分表查询参数
+	fanOut *FanOutOptionsBuilder
+}
+
+type RepositoryOptionsBuilder struct {
+	funcs []RepositoryOptionFunc
+}
+
+func RepositoryBuilder() *RepositoryOptionsBuilder {
+	return &RepositoryOptionsBuilder{}
+}
+
+type RepositoryOptionFunc func(*RepositoryOption)
+
+// Table 分表配置，与 TableBuilder 相同，ThisTime 由数据的分表时间决定
+func (rb *RepositoryOptionsBuilder) Table(table *TableOptionsBuilder) *RepositoryOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RepositoryOption) {
+		opt.table = table
+	})
+	return rb
+}
+
+// Cluster 读写分离，设置后不需要在 Table 中设置 MysqlClient，不能与 Table 中的 Placement 同时使用
+func (rb *RepositoryOptionsBuilder) Cluster(cluster *Cluster) *RepositoryOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RepositoryOption) {
+		opt.cluster = cluster
+	})
+	return rb
+}
+
+// BatchSize 每条 INSERT 最多写入的行数，默认 500
+func (rb *RepositoryOptionsBuilder) BatchSize(size int) *RepositoryOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RepositoryOption) {
+		opt.batchSize = size
+	})
+	return rb
+}
+
+// FanOut FindRange 跨分表查询的并发数、链路追踪
+func (rb *RepositoryOptionsBuilder) FanOut(fanOut *FanOutOptionsBuilder) *RepositoryOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RepositoryOption) {
+		opt.fanOut = fanOut
+	})
+	return rb
+}
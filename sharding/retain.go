@@ -0,0 +1,196 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/line-lee/toolkit/beankit"
+	"log/slog"
+	"time"
+)
+
+// RetainResult 过期分表清理结果
+type RetainResult struct {
+	// 保留的分表数量
+	Kept int
+	// 已删除的分表，DryRun 时为空
+	Dropped []string
+	// 删除失败的分表，key 为表名
+	Failed map[string]error
+	// DryRun 时将要执行的删表语句
+	Plan *Plan
+}
+
+// Retain 删除基础表 primary 中时间范围已整体早于 Before 的分表，设置 Registry 时同步标记为已删除
+func Retain(ctx context.Context, builder *RetainOptionsBuilder) (*RetainResult, error) {
+	option := new(RetainOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if option.mysqlClient == nil {
+		return nil, invalidOption("MysqlClient", "sharding.Retain，option MysqlClient 必填")
+	}
+	if beankit.IsStringBlank(option.db) {
+		return nil, invalidOption("DBName", "sharding.Retain，option DBName 必填")
+	}
+	if beankit.IsStringBlank(option.primary) {
+		return nil, invalidOption("Primary", "sharding.Retain，option Primary 必填")
+	}
+	if option.before.IsZero() {
+		return nil, invalidOption("Before", "sharding.Retain，option Before 必填")
+	}
+	shards, err := ListShards(ctx, option.mysqlClient, option.db, option.primary)
+	if err != nil {
+		return nil, err
+	}
+	var result = &RetainResult{Dropped: make([]string, 0), Failed: make(map[string]error)}
+	if option.dryRun {
+		result.Plan = new(Plan)
+	}
+	for _, shard := range shards {
+		if shard.End.After(option.before) {
+			result.Kept++
+			continue
+		}
+		var dropSql = dropShardSql(option.db, shard.Table)
+		if option.dryRun {
+			result.Plan.add(shard.Table, dropSql, fmt.Sprintf("分表数据早于 %s，超出保留期", option.before.Format("2006-01-02 15:04:05")))
+			continue
+		}
+		if err = option.drop(ctx, shard.Table, dropSql); err != nil {
+			result.Failed[shard.Table] = err
+			continue
+		}
+		result.Dropped = append(result.Dropped, shard.Table)
+	}
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("sharding.Retain，%d 张分表删除失败", len(result.Failed))
+	}
+	return result, nil
+}
+
+// drop 通过 MysqlClient 删除库 db 中的一张分表，设置 Registry 时再标记为已删除，登记表可以在其他连接或库中
+func (ro *RetainOption) drop(ctx context.Context, table, dropSql string) error {
+	if _, err := ro.mysqlClient.ExecContext(ctx, dropSql); err != nil {
+		ro.getLogger().ErrorContext(ctx, "sharding.Retain，分表删除失败", slog.String("table", table), slog.String("sql", dropSql), slog.Any("err", err))
+		return &DDLError{SQL: dropSql, Cause: err}
+	}
+	ro.getLogger().InfoContext(ctx, "sharding.Retain，分表已删除", slog.String("table", table))
+	ro.getCache().Invalidate(ctx, CacheKey(ro.db, table))
+	if ro.registry != nil {
+		return ro.registry.MarkDropped(ctx, ro.primary, table)
+	}
+	return nil
+}
+
+// dropShardSql 删表语句
+func dropShardSql(db, table string) string {
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", quote(db), quote(table))
+}
+
+// RetainOption 过期分表清理参数，由 RetainBuilder 传入
+type RetainOption struct {
+	// 数据库连接
+	mysqlClient *sql.DB
+	// 库名
+	db string
+	// 基础表名
+	primary string
+	// 保留截止时间，结束时间不晚于该时间的分表会被删除
+	before time.Time
+	// 分表登记表，设置后删表成功时标记为已删除
+	registry *Registry
+	// 只计算删表语句，不执行
+	dryRun bool
+	// 分表存在性缓存，删表后失效，默认 DefaultCache()
+	cache Cache
+	// 日志，默认 slog.Default()
+	logger *slog.Logger
+}
+
+func (ro *RetainOption) getCache() Cache {
+	if ro.cache != nil {
+		return ro.cache
+	}
+	return DefaultCache()
+}
+
+// getLogger 日志默认使用 slog.Default()，统一带上库名、基础表名
+func (ro *RetainOption) getLogger() *slog.Logger {
+	var logger = ro.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With(slog.String("db", ro.db), slog.String("primary", ro.primary))
+}
+
+type RetainOptionsBuilder struct {
+	funcs []RetainOptionFunc
+}
+
+func RetainBuilder() *RetainOptionsBuilder {
+	return &RetainOptionsBuilder{}
+}
+
+type RetainOptionFunc func(*RetainOption)
+
+func (rb *RetainOptionsBuilder) MysqlClient(mysqlClient *sql.DB) *RetainOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RetainOption) {
+		opt.mysqlClient = mysqlClient
+	})
+	return rb
+}
+
+func (rb *RetainOptionsBuilder) DBName(dbName string) *RetainOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RetainOption) {
+		opt.db = dbName
+	})
+	return rb
+}
+
+func (rb *RetainOptionsBuilder) Primary(primary string) *RetainOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RetainOption) {
+		opt.primary = primary
+	})
+	return rb
+}
+
+// Before 保留截止时间，分表结束时间（不包含）不晚于 before 时删除，例如保留30天：time.Now().AddDate(0, 0, -30)
+func (rb *RetainOptionsBuilder) Before(before time.Time) *RetainOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RetainOption) {
+		opt.before = before
+	})
+	return rb
+}
+
+// Registry 分表登记表，删表仍通过 MysqlClient、DBName 执行，成功后在登记表中标记为已删除
+func (rb *RetainOptionsBuilder) Registry(registry *Registry) *RetainOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RetainOption) {
+		opt.registry = registry
+	})
+	return rb
+}
+
+// DryRun 只计算删表语句，写入 RetainResult.Plan，不执行
+func (rb *RetainOptionsBuilder) DryRun() *RetainOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RetainOption) {
+		opt.dryRun = true
+	})
+	return rb
+}
+
+// Cache 分表存在性缓存，删表后失效，与 TableBuilder().Cache() 使用同一个缓存，多实例时使用 RedisCache 广播
+func (rb *RetainOptionsBuilder) Cache(c Cache) *RetainOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RetainOption) {
+		opt.cache = c
+	})
+	return rb
+}
+
+// Logger 设置日志，默认 slog.Default()
+func (rb *RetainOptionsBuilder) Logger(logger *slog.Logger) *RetainOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RetainOption) {
+		opt.logger = logger
+	})
+	return rb
+}
@@ -0,0 +1,108 @@
+package sharding
+
+import (
+	"fmt"
+	"github.com/line-lee/toolkit/beankit"
+	"sort"
+)
+
+// RouteResult 按 key 路由的查询参数，一张分表一条
+type RouteResult struct {
+	TableName string
+	// 分表后缀
+	Shard string
+	// 落在该分表的 key，未传入 key（全表扫描）时为空
+	Keys []int64
+}
+
+// Route 按分表策略把一组 key 映射到需要查询的分表，结果按表名排序；未传入 key 时返回策略的所有分表
+func Route(builder *RouteOptionsBuilder) ([]*RouteResult, error) {
+	option := new(RouteOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if beankit.IsStringBlank(option.primary) {
+		return nil, invalidOption("Primary", "sharding.Route，option Primary 必填")
+	}
+	if option.strategy == nil {
+		return nil, invalidOption("Strategy", "sharding.Route，option Strategy 必填")
+	}
+	return routeKeys(option.primary, option.strategy, option.keys)
+}
+
+// routeKeys 按 key 分组到分表，keys 为空时返回策略的所有分表
+func routeKeys(primary string, strategy KeyStrategy, keys []int64) ([]*RouteResult, error) {
+	var results = make([]*RouteResult, 0)
+	if len(keys) == 0 {
+		for _, suffix := range strategy.Shards() {
+			if err := checkShardSuffix(suffix); err != nil {
+				return nil, err
+			}
+			results = append(results, &RouteResult{TableName: fmt.Sprintf("%s_%s", primary, suffix), Shard: suffix})
+		}
+		return results, nil
+	}
+	var byTable = make(map[string]*RouteResult)
+	var seen = make(map[int64]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		suffix, err := shardSuffix(strategy, key)
+		if err != nil {
+			return nil, err
+		}
+		var tableName = fmt.Sprintf("%s_%s", primary, suffix)
+		if byTable[tableName] == nil {
+			byTable[tableName] = &RouteResult{TableName: tableName, Shard: suffix}
+			results = append(results, byTable[tableName])
+		}
+		byTable[tableName].Keys = append(byTable[tableName].Keys, key)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].TableName < results[j].TableName })
+	return results, nil
+}
+
+// RouteOption 路由参数，由 RouteBuilder 传入
+type RouteOption struct {
+	// 基础表名
+	primary string
+	// 分表策略
+	strategy KeyStrategy
+	// 查询的 key
+	keys []int64
+}
+
+type RouteOptionsBuilder struct {
+	funcs []RouteOptionFunc
+}
+
+func RouteBuilder() *RouteOptionsBuilder {
+	return &RouteOptionsBuilder{}
+}
+
+type RouteOptionFunc func(*RouteOption)
+
+func (rb *RouteOptionsBuilder) Primary(primary string) *RouteOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RouteOption) {
+		opt.primary = primary
+	})
+	return rb
+}
+
+// Strategy 分表策略，需要与建表时 TableBuilder().Key() 使用的策略一致
+func (rb *RouteOptionsBuilder) Strategy(strategy KeyStrategy) *RouteOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RouteOption) {
+		opt.strategy = strategy
+	})
+	return rb
+}
+
+// Keys 追加查询的 key，重复的 key 只保留一个
+func (rb *RouteOptionsBuilder) Keys(keys ...int64) *RouteOptionsBuilder {
+	rb.funcs = append(rb.funcs, func(opt *RouteOption) {
+		opt.keys = append(opt.keys, keys...)
+	})
+	return rb
+}
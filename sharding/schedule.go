@@ -0,0 +1,61 @@
+package sharding
+
+import (
+	"fmt"
+	"time"
+)
+
+// Cutover 分表粒度调整计划中的一项，From 起（包含）使用 Type 分表，例如原来按月分表，2025-09-01 起按天分表：
+//
+//	[]Cutover{{Type: Month}, {From: time.Date(2025, 9, 1, 0, 0, 0, 0, time.Local), Type: Day}}
+//
+// 第一项之前的时间也使用第一项，From 可以为零值；调整时间不需要对齐分表边界，跨调整时间的分表只包含调整前（后）的部分
+type Cutover struct {
+	// 生效时间（包含）
+	From time.Time
+	// 分表类型
+	Type Type
+	// 分表名前缀，为空时使用 Primary，例如调整粒度时同时启用新的基础表 orders_v2
+	Primary string
+}
+
+// validateSchedule 校验调整计划：至少一项，生效时间递增，分表类型可识别
+func validateSchedule(schedule []Cutover) error {
+	if len(schedule) == 0 {
+		return invalidOption("Schedule", "sharding，option Schedule 至少需要一项")
+	}
+	for i, cutover := range schedule {
+		if cutover.Type.layout() == "" {
+			return fmt.Errorf("sharding，Schedule 第 %d 项 type %d：%w", i, cutover.Type, ErrUnknownType)
+		}
+		if i > 0 && !cutover.From.After(schedule[i-1].From) {
+			return invalidOption("Schedule", fmt.Sprintf("sharding，option Schedule 生效时间需要递增，第 %d 项 %s", i, cutover.From.Format(time.DateTime)))
+		}
+	}
+	return nil
+}
+
+// cutoverAt 时间 tm 使用的一项
+func cutoverAt(schedule []Cutover, tm time.Time) Cutover {
+	var current = schedule[0]
+	for _, cutover := range schedule[1:] {
+		if tm.Before(cutover.From) {
+			break
+		}
+		current = cutover
+	}
+	return current
+}
+
+// scheduleSegments 按调整时间把查询范围切成使用不同分表类型的几段
+func scheduleSegments(schedule []Cutover, start, end time.Time, isEndClose bool) []*segment {
+	var levels = make([]*segment, len(schedule))
+	var cuts = make([]time.Time, 0, len(schedule)-1)
+	for i, cutover := range schedule {
+		levels[i] = &segment{t: cutover.Type, primary: cutover.Primary}
+		if i > 0 {
+			cuts = append(cuts, cutover.From)
+		}
+	}
+	return cutSegments(levels, cuts, start, end, isEndClose)
+}
@@ -0,0 +1,95 @@
+package sharding
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// CREATE TABLE / CREATE TABLE IF NOT EXISTS
+var createTableRegexp = regexp.MustCompile(`(?i)^\s*CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?`)
+
+// 建表语句的表名：db.table、table，可带反引号
+var createTargetRegexp = regexp.MustCompile("^(?:(`(?:[^`]|``)+`|[\\w$]+)\\s*\\.\\s*)?(`(?:[^`]|``)+`|[\\w$]+)")
+
+// SchemaData 建表模板参数，模板中通过 {{.Table}} 等方式引用
+type SchemaData struct {
+	// 库名
+	DB string
+	// 基础表名
+	Primary string
+	// 分表名
+	Table string
+	// 按 key 分表时的分表后缀
+	Shard string
+	// 分表时间范围，左闭右开，按 key 分表时为零值
+	Start time.Time
+	End   time.Time
+}
+
+// parseSchema 解析建表模板，并使用示例参数试渲染，提前暴露模板错误
+func parseSchema(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, invalidOption("Schema", "sharding.Schema，建表模板不能为空")
+	}
+	tpl, err := template.New("schema").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, &ErrInvalidOption{Field: "Schema", Msg: fmt.Sprintf("sharding.Schema，建表模板解析失败：%v", err), Cause: err}
+	}
+	var start, end = Day.Bucket(time.Now())
+	if _, err = renderSchema(tpl, &SchemaData{DB: "db", Primary: "primary", Table: "primary_20060102", Shard: "00", Start: start, End: end}); err != nil {
+		return nil, &ErrInvalidOption{Field: "Schema", Msg: err.Error(), Cause: err}
+	}
+	return tpl, nil
+}
+
+// readSchema 从 fsys 中读取建表模板
+func readSchema(fsys fs.FS, path string) (string, error) {
+	if fsys == nil {
+		return "", invalidOption("SchemaFS", "sharding.SchemaFS，fs.FS 不能为空")
+	}
+	text, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return "", &ErrInvalidOption{Field: "SchemaFS", Msg: fmt.Sprintf("sharding.SchemaFS，建表模板读取失败：%v", err), Cause: err}
+	}
+	return string(text), nil
+}
+
+// renderSchema 渲染建表语句，统一成 CREATE TABLE IF NOT EXISTS，避免并发建表冲突；
+// 表名必须是 data.Table、库名省略或为 data.DB，否则 IF NOT EXISTS 会跳过建表，分表实际不存在
+func renderSchema(tpl *template.Template, data *SchemaData) (string, error) {
+	createSql, err := executeTemplate(tpl, data)
+	if err != nil {
+		return "", fmt.Errorf("sharding.Schema，建表模板渲染失败：%w", err)
+	}
+	var head = createTableRegexp.FindString(createSql)
+	if head == "" {
+		return "", fmt.Errorf("sharding.Schema，建表模板必须是 CREATE TABLE 语句：%.64s", strings.TrimSpace(createSql))
+	}
+	var m = createTargetRegexp.FindStringSubmatch(createSql[len(head):])
+	if m == nil || (m[1] != "" && unquote(m[1]) != data.DB) || unquote(m[2]) != data.Table {
+		return "", fmt.Errorf("sharding.Schema，建表模板的表名需要是 `{{.DB}}`.`{{.Table}}`，渲染结果：%.64s", strings.TrimSpace(createSql))
+	}
+	return strings.TrimSpace(createTableRegexp.ReplaceAllLiteralString(createSql, "CREATE TABLE IF NOT EXISTS ")), nil
+}
+
+// unquote 去掉标识符的反引号
+func unquote(name string) string {
+	if len(name) >= 2 && name[0] == '`' && name[len(name)-1] == '`' {
+		return strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+	}
+	return name
+}
+
+// executeTemplate 渲染 sql 模板
+func executeTemplate(tpl *template.Template, data *SchemaData) (string, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,240 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/line-lee/toolkit/beankit"
+	"time"
+)
+
+// 阈值检查的指标名
+const (
+	MetricRows        = "rows"
+	MetricDataLength  = "data_length"
+	MetricIndexLength = "index_length"
+	MetricIndexRatio  = "index_ratio"
+	MetricRowsGrowth  = "rows_growth"
+	MetricDataGrowth  = "data_growth"
+)
+
+// ShardStats 单张分表的容量统计，来自 information_schema.TABLES，行数为估算值
+// mysql 8 默认缓存统计信息 information_schema_stats_expiry 秒，需要实时数据时先 ANALYZE TABLE
+type ShardStats struct {
+	// 分表名
+	Table string `json:"table"`
+	// 分表时间范围，左闭右开
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// 估算行数
+	Rows int64 `json:"rows"`
+	// 数据大小、索引大小，单位字节
+	DataLength  int64 `json:"data_length"`
+	IndexLength int64 `json:"index_length"`
+	// 最后更新时间，InnoDB 重启后可能为空
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	// 与前一张同类型分表相比的增长率，0.5 表示增长 50%，没有前一张分表或前一张为空时为 0
+	RowsGrowth float64 `json:"rows_growth"`
+	DataGrowth float64 `json:"data_growth"`
+}
+
+// IndexRatio 索引大小与数据大小的比值，数据为空时为 0
+func (ss *ShardStats) IndexRatio() float64 {
+	if ss.DataLength == 0 {
+		return 0
+	}
+	return float64(ss.IndexLength) / float64(ss.DataLength)
+}
+
+// Violation 超出阈值的指标
+type Violation struct {
+	// 分表名
+	Table string `json:"table"`
+	// 指标名，见 MetricRows 等常量
+	Metric string `json:"metric"`
+	// 当前值
+	Value float64 `json:"value"`
+	// 阈值
+	Limit float64 `json:"limit"`
+}
+
+// StatsReport 容量统计结果
+type StatsReport struct {
+	// 按时间排序的分表统计
+	Shards []*ShardStats `json:"shards"`
+	// 超出阈值的指标，按分表、指标顺序排列
+	Violations []*Violation `json:"violations"`
+}
+
+// Stats 统计基础表 primary 所有分表的行数、数据大小、索引大小、最后更新时间和相邻分表的增长率，并按阈值检查
+func Stats(ctx context.Context, builder *StatsOptionsBuilder) (*StatsReport, error) {
+	option := new(StatsOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if option.mysqlClient == nil {
+		return nil, invalidOption("MysqlClient", "sharding.Stats，option MysqlClient 必填")
+	}
+	if beankit.IsStringBlank(option.db) {
+		return nil, invalidOption("DBName", "sharding.Stats，option DBName 必填")
+	}
+	if beankit.IsStringBlank(option.primary) {
+		return nil, invalidOption("Primary", "sharding.Stats，option Primary 必填")
+	}
+	shards, err := ListShards(ctx, option.mysqlClient, option.db, option.primary)
+	if err != nil {
+		return nil, err
+	}
+	const statsSql = "SELECT TABLE_NAME, IFNULL(TABLE_ROWS, 0), IFNULL(DATA_LENGTH, 0), IFNULL(INDEX_LENGTH, 0), IFNULL(UNIX_TIMESTAMP(UPDATE_TIME), 0) " +
+		"FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME LIKE ?"
+	var loaded = make(map[string]*ShardStats)
+	err = queryEach(ctx, option.mysqlClient, statsSql, []any{option.db, likePrefix(option.primary + "_")}, func(rows *sql.Rows) error {
+		var stats = new(ShardStats)
+		var updated int64
+		if err := rows.Scan(&stats.Table, &stats.Rows, &stats.DataLength, &stats.IndexLength, &updated); err != nil {
+			return err
+		}
+		if updated > 0 {
+			stats.UpdatedAt = time.Unix(updated, 0)
+		}
+		loaded[stats.Table] = stats
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sharding.Stats，分表统计查询失败：%w", err)
+	}
+	var report = &StatsReport{Shards: make([]*ShardStats, 0, len(shards)), Violations: make([]*Violation, 0)}
+	var previous = make(map[Type]*ShardStats)
+	for _, shard := range shards {
+		var stats = loaded[shard.Table]
+		if stats == nil {
+			// 查询期间被删除的分表
+			continue
+		}
+		stats.Start, stats.End = shard.Start, shard.End
+		if prev := previous[shard.Type]; prev != nil {
+			stats.RowsGrowth = growth(prev.Rows, stats.Rows)
+			stats.DataGrowth = growth(prev.DataLength, stats.DataLength)
+		}
+		previous[shard.Type] = stats
+		report.Shards = append(report.Shards, stats)
+		report.Violations = append(report.Violations, option.check(stats)...)
+	}
+	return report, nil
+}
+
+// growth 增长率
+func growth(prev, current int64) float64 {
+	if prev == 0 {
+		return 0
+	}
+	return float64(current-prev) / float64(prev)
+}
+
+// check 按阈值检查单张分表，未设置的阈值不检查
+func (so *StatsOption) check(stats *ShardStats) []*Violation {
+	var violations = make([]*Violation, 0)
+	var exceed = func(metric string, value, limit float64) {
+		if limit > 0 && value > limit {
+			violations = append(violations, &Violation{Table: stats.Table, Metric: metric, Value: value, Limit: limit})
+		}
+	}
+	exceed(MetricRows, float64(stats.Rows), float64(so.maxRows))
+	exceed(MetricDataLength, float64(stats.DataLength), float64(so.maxDataLength))
+	exceed(MetricIndexLength, float64(stats.IndexLength), float64(so.maxIndexLength))
+	exceed(MetricIndexRatio, stats.IndexRatio(), so.maxIndexRatio)
+	exceed(MetricRowsGrowth, stats.RowsGrowth, so.maxGrowth)
+	exceed(MetricDataGrowth, stats.DataGrowth, so.maxGrowth)
+	return violations
+}
+
+// StatsOption 容量统计参数，由 StatsBuilder 传入，阈值为 0 时不检查
+type StatsOption struct {
+	// 数据库连接
+	mysqlClient *sql.DB
+	// 库名
+	db string
+	// 基础表名
+	primary string
+	// 单张分表估算行数上限
+	maxRows int64
+	// 单张分表数据大小上限，单位字节
+	maxDataLength int64
+	// 单张分表索引大小上限，单位字节
+	maxIndexLength int64
+	// 索引大小与数据大小比值上限
+	maxIndexRatio float64
+	// 相邻分表行数、数据大小增长率上限
+	maxGrowth float64
+}
+
+type StatsOptionsBuilder struct {
+	funcs []StatsOptionFunc
+}
+
+func StatsBuilder() *StatsOptionsBuilder {
+	return &StatsOptionsBuilder{}
+}
+
+type StatsOptionFunc func(*StatsOption)
+
+func (sb *StatsOptionsBuilder) MysqlClient(mysqlClient *sql.DB) *StatsOptionsBuilder {
+	sb.funcs = append(sb.funcs, func(opt *StatsOption) {
+		opt.mysqlClient = mysqlClient
+	})
+	return sb
+}
+
+func (sb *StatsOptionsBuilder) DBName(dbName string) *StatsOptionsBuilder {
+	sb.funcs = append(sb.funcs, func(opt *StatsOption) {
+		opt.db = dbName
+	})
+	return sb
+}
+
+func (sb *StatsOptionsBuilder) Primary(primary string) *StatsOptionsBuilder {
+	sb.funcs = append(sb.funcs, func(opt *StatsOption) {
+		opt.primary = primary
+	})
+	return sb
+}
+
+// MaxRows 单张分表估算行数上限
+func (sb *StatsOptionsBuilder) MaxRows(rows int64) *StatsOptionsBuilder {
+	sb.funcs = append(sb.funcs, func(opt *StatsOption) {
+		opt.maxRows = rows
+	})
+	return sb
+}
+
+// MaxDataLength 单张分表数据大小上限，单位字节
+func (sb *StatsOptionsBuilder) MaxDataLength(bytes int64) *StatsOptionsBuilder {
+	sb.funcs = append(sb.funcs, func(opt *StatsOption) {
+		opt.maxDataLength = bytes
+	})
+	return sb
+}
+
+// MaxIndexLength 单张分表索引大小上限，单位字节
+func (sb *StatsOptionsBuilder) MaxIndexLength(bytes int64) *StatsOptionsBuilder {
+	sb.funcs = append(sb.funcs, func(opt *StatsOption) {
+		opt.maxIndexLength = bytes
+	})
+	return sb
+}
+
+// MaxIndexRatio 索引大小与数据大小比值上限，例如 1.5
+func (sb *StatsOptionsBuilder) MaxIndexRatio(ratio float64) *StatsOptionsBuilder {
+	sb.funcs = append(sb.funcs, func(opt *StatsOption) {
+		opt.maxIndexRatio = ratio
+	})
+	return sb
+}
+
+// MaxGrowth 相邻分表行数、数据大小增长率上限，例如 1 表示比前一张分表增长超过 100% 时告警
+func (sb *StatsOptionsBuilder) MaxGrowth(rate float64) *StatsOptionsBuilder {
+	sb.funcs = append(sb.funcs, func(opt *StatsOption) {
+		opt.maxGrowth = rate
+	})
+	return sb
+}
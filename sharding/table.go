@@ -3,13 +3,13 @@ package sharding
 import (
 	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"github.com/line-lee/toolkit/beankit"
 	"github.com/redis/go-redis/v9"
-	"log"
-	"strings"
-	"sync"
+	"go.opentelemetry.io/otel/trace"
+	"io/fs"
+	"log/slog"
+	"text/template"
 	"time"
 )
 
@@ -20,42 +20,88 @@ func New(builder *TableOptionsBuilder) *TableOption {
 	for _, op := range builder.funcs {
 		op(option)
 	}
-	if option.mysqlClient == nil {
-		return &TableOption{err: errors.New("分表初始化对象,New()参数中， option WithMysqlClient 必填")}
+	if option.err != nil {
+		return &TableOption{err: option.err}
+	}
+	// 设置放置规则时，数据库连接和库名由规则决定
+	if option.mysqlClient == nil && option.placement == nil {
+		return &TableOption{err: invalidOption("MysqlClient", "分表初始化对象,New()参数中， option WithMysqlClient 必填")}
 	}
 	if option.redisClient == nil {
-		return &TableOption{err: errors.New("分表初始化对象,New()参数中， option WithRedisClient 必填")}
+		return &TableOption{err: invalidOption("RedisClient", "分表初始化对象,New()参数中， option WithRedisClient 必填")}
 	}
-	if beankit.IsStringBlank(option.db) {
-		return &TableOption{err: errors.New("分表初始化对象,New()参数中， option WithDBName 必填")}
+	if beankit.IsStringBlank(option.db) && option.placement == nil {
+		return &TableOption{err: invalidOption("DBName", "分表初始化对象,New()参数中， option WithDBName 必填")}
 	}
 	if beankit.IsStringBlank(option.primary) {
-		return &TableOption{err: errors.New("分表初始化对象,New()参数中， option WithPrimary 必填")}
-	}
-	if option.thisTime.IsZero() {
-		return &TableOption{err: errors.New("分表初始化对象,New()参数中， option WithThisTime 必填")}
-	}
-	if option.t == 0 {
-		return &TableOption{err: errors.New("分表初始化对象,New()参数中， option WithThisTime 必填")}
-	}
-	var suffix string
-	switch option.t {
-	// 2006-01-02 15:04:05
-	case Hour:
-		suffix = option.thisTime.Format("2006010215")
-	case Day:
-		suffix = option.thisTime.Format("20060102")
-	case Month:
-		suffix = option.thisTime.Format("200601")
-	case Year:
-		suffix = option.thisTime.Format("2006")
-	default:
-		return &TableOption{err: fmt.Errorf("mysql分表，分表类型不识别，shard type %d", option.t)}
+		return &TableOption{err: invalidOption("Primary", "分表初始化对象,New()参数中， option WithPrimary 必填")}
+	}
+	if err := validateUnit(option.unit); err != nil {
+		return &TableOption{err: err}
+	}
+	if len(option.schedule) > 0 {
+		if err := validateSchedule(option.schedule); err != nil {
+			return &TableOption{err: err}
+		}
+		var cutover = cutoverAt(option.schedule, option.thisTime)
+		option.t = cutover.Type
+		if cutover.Primary != "" {
+			option.primary = cutover.Primary
+		}
+	}
+	suffix, err := option.suffix()
+	if err != nil {
+		return &TableOption{err: err}
+	}
+	if option.placement != nil {
+		var start, _ = option.t.Bucket(option.thisTime)
+		target, err := option.placement.Resolve(option.keySuffix, start)
+		if err != nil {
+			return &TableOption{err: err}
+		}
+		option.mysqlClient, option.db = target.Client, target.DB
+		option.target = target
 	}
 	option.expect = fmt.Sprintf("%s_%s", option.primary, suffix)
 	return option
 }
 
+// suffix 分表后缀：按 key 分表时为策略计算的后缀，按时间分表时为当前时间所在的时间分表，
+// 同时设置时组合为 key后缀_时间，例如 orders_t42_202508
+func (to *TableOption) suffix() (string, error) {
+	if to.keyStrategy == nil {
+		return to.timeSuffix()
+	}
+	keySuffix, err := shardSuffix(to.keyStrategy, to.key)
+	if err != nil {
+		return "", err
+	}
+	to.keySuffix = keySuffix
+	if to.thisTime.IsZero() && to.t == 0 {
+		return keySuffix, nil
+	}
+	timeSuffix, err := to.timeSuffix()
+	if err != nil {
+		return "", err
+	}
+	return keySuffix + "_" + timeSuffix, nil
+}
+
+// timeSuffix 当前时间所在时间分表的后缀
+func (to *TableOption) timeSuffix() (string, error) {
+	if to.thisTime.IsZero() {
+		return "", invalidOption("ThisTime", "分表初始化对象,New()参数中， option WithThisTime 必填")
+	}
+	if to.t == 0 {
+		return "", invalidOption("Type", "分表初始化对象,New()参数中， option WithType 必填")
+	}
+	var layout = to.t.layout()
+	if layout == "" {
+		return "", fmt.Errorf("mysql分表，分表类型不识别，shard type %d：%w", to.t, ErrUnknownType)
+	}
+	return to.thisTime.Format(layout), nil
+}
+
 type TableOption struct {
 	// 数据库连接，用于分表检查和创建分表
 	mysqlClient *sql.DB
@@ -69,6 +115,34 @@ type TableOption struct {
 	thisTime time.Time
 	// 分表类型
 	t Type
+	// 分表粒度调整计划，设置后按 thisTime 选择分表类型
+	schedule []Cutover
+	// 时间列为 unix 时间戳时的单位和换算分表时间使用的时区
+	unit UnixUnit
+	loc  *time.Location
+	// 按 key 分表的策略和 key，设置后不按时间分表
+	keyStrategy KeyStrategy
+	key         int64
+	keySuffix   string
+	// 分表放置规则和分表所在的数据库
+	placement *Placement
+	target    *Target
+	// 建表语句改写规则
+	ddl *DDLOptionsBuilder
+	// 建表模板，设置后不再复制基础表结构，在 Schema、SchemaFS 中解析
+	schema *template.Template
+	// 建表模板已包含的迁移，按模板新建分表时写入迁移记录
+	schemaMigrations []*Migration
+	// 分表登记表，设置后新建分表时登记
+	registry *Registry
+	// 分表存在性缓存
+	cache Cache
+	// 日志，默认 slog.Default()
+	logger *slog.Logger
+	// 观测回调，默认不观测
+	observer Observer
+	// 链路追踪，默认不产生 span
+	tracerProvider trace.TracerProvider
 
 	// expect 分表名
 	expect string
@@ -93,6 +167,18 @@ func (tb *TableOptionsBuilder) MysqlClient(mysqlClient *sql.DB) *TableOptionsBui
 	return tb
 }
 
+// Cluster 读写分离时使用主库建表，等同于 MysqlClient(cluster.Writer())
+func (tb *TableOptionsBuilder) Cluster(cluster *Cluster) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		if cluster == nil {
+			opt.err = invalidOption("Cluster", "分表初始化对象,New()参数中， option Cluster 不能为 nil")
+			return
+		}
+		opt.mysqlClient = cluster.Writer()
+	})
+	return tb
+}
+
 func (tb *TableOptionsBuilder) RedisClient(client *redis.Client) *TableOptionsBuilder {
 	tb.funcs = append(tb.funcs, func(opt *TableOption) {
 		opt.redisClient = client
@@ -121,6 +207,22 @@ func (tb *TableOptionsBuilder) ThisTime(thisTime time.Time) *TableOptionsBuilder
 	return tb
 }
 
+// ThisUnix 以时间戳传入当前时间，loc 为分表名使用的时区，为 nil 时使用 time.Local
+func (tb *TableOptionsBuilder) ThisUnix(value int64, unit UnixUnit, loc *time.Location) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.thisTime = unit.Time(value, loc)
+	})
+	return tb
+}
+
+// Unix 时间列为 unix 时间戳，Repository、Compact 生成的 SQL 使用时间戳，loc 为时间戳换算分表时间使用的时区，为 nil 时使用 time.Local
+func (tb *TableOptionsBuilder) Unix(unit UnixUnit, loc *time.Location) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.unit, opt.loc = unit, loc
+	})
+	return tb
+}
+
 func (tb *TableOptionsBuilder) Type(t Type) *TableOptionsBuilder {
 	tb.funcs = append(tb.funcs, func(opt *TableOption) {
 		opt.t = t
@@ -128,55 +230,350 @@ func (tb *TableOptionsBuilder) Type(t Type) *TableOptionsBuilder {
 	return tb
 }
 
-// 缓存某些关键信息，减少sql查询
-var cache sync.Map
+// Key 按 key 分表，分表名为 primary_策略计算的后缀，例如 Modulo{N: 64} 时 user_07
+// 同时设置 ThisTime、Type 时为组合分表 primary_key后缀_时间，例如 Identity{Prefix: "t"} 按月分表时 orders_t42_202508
+func (tb *TableOptionsBuilder) Key(strategy KeyStrategy, key int64) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.keyStrategy = strategy
+		opt.key = key
+	})
+	return tb
+}
+
+// Schedule 分表粒度调整计划，按 ThisTime 所在的一项选择分表类型和分表名前缀，设置后不需要 Type
+func (tb *TableOptionsBuilder) Schedule(schedule ...Cutover) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.schedule = schedule
+	})
+	return tb
+}
+
+// Placement 按放置规则选择分表所在的数据库实例和库，设置后不需要 MysqlClient、DBName，
+// 分表在目标库中建表，目标库需要有基础表或使用 Schema 建表模板；写入时使用 Target() 的连接
+func (tb *TableOptionsBuilder) Placement(placement *Placement) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.placement = placement
+	})
+	return tb
+}
+
+// DDL 分表建表语句改写规则，默认去掉 AUTO_INCREMENT 并按分表改写约束名
+func (tb *TableOptionsBuilder) DDL(ddl *DDLOptionsBuilder) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.ddl = ddl
+	})
+	return tb
+}
+
+// Schema 使用 text/template 建表模板创建分表，不再依赖基础表，模板参数见 SchemaData，例如：
+// CREATE TABLE `{{.DB}}`.`{{.Table}}` (`id` BIGINT NOT NULL AUTO_INCREMENT, PRIMARY KEY (`id`)) ENGINE=InnoDB
+// 模板在这里解析一次，建表的表名必须是 {{.Table}}，库名可以省略或为 {{.DB}}，错误由 GetTableName 返回；ddl 为空时不使用模板
+func (tb *TableOptionsBuilder) Schema(ddl string) *TableOptionsBuilder {
+	var tpl *template.Template
+	var err error
+	if ddl != "" {
+		tpl, err = parseSchema(ddl)
+	}
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.schema = tpl
+		if err != nil {
+			opt.err = err
+		}
+	})
+	return tb
+}
+
+// SchemaFS 从 fsys 的 path 文件读取建表模板，可以配合 embed.FS 使用，模板写法同 Schema，文件在这里读取、解析一次
+func (tb *TableOptionsBuilder) SchemaFS(fsys fs.FS, path string) *TableOptionsBuilder {
+	text, err := readSchema(fsys, path)
+	var tpl *template.Template
+	if err == nil {
+		tpl, err = parseSchema(text)
+	}
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.schema = tpl
+		if err != nil {
+			opt.err = err
+		}
+	})
+	return tb
+}
+
+// SchemaMigrations 建表模板已包含的迁移，通常与 MigrateBuilder().Migrations 传入同一个列表，
+// 按模板新建分表时写入这些版本的迁移记录，Migrate 不再对新分表重复执行；修改模板时同步追加迁移
+func (tb *TableOptionsBuilder) SchemaMigrations(migrations ...*Migration) *TableOptionsBuilder {
+	sorted, err := prepareMigrations(migrations)
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.schemaMigrations = sorted
+		if err != nil {
+			opt.err = err
+		}
+	})
+	return tb
+}
+
+// Registry 新建分表时写入分表登记表
+func (tb *TableOptionsBuilder) Registry(registry *Registry) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.registry = registry
+	})
+	return tb
+}
+
+// Cache 分表存在性缓存，默认使用 DefaultCache()，多实例部署可使用 NewRedisCache
+func (tb *TableOptionsBuilder) Cache(c Cache) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.cache = c
+	})
+	return tb
+}
+
+// Logger 设置日志，默认 slog.Default()，缓存命中、加锁等输出 debug 日志
+func (tb *TableOptionsBuilder) Logger(logger *slog.Logger) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.logger = logger
+	})
+	return tb
+}
+
+// Observer 设置观测回调，接入监控，例如 NewMetrics()
+func (tb *TableOptionsBuilder) Observer(observer Observer) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.observer = observer
+	})
+	return tb
+}
+
+// TracerProvider 设置链路追踪，每次获取分表名一个 sharding.GetTableName span，加锁、建表为子 span
+func (tb *TableOptionsBuilder) TracerProvider(tp trace.TracerProvider) *TableOptionsBuilder {
+	tb.funcs = append(tb.funcs, func(opt *TableOption) {
+		opt.tracerProvider = tp
+	})
+	return tb
+}
 
 func (to *TableOption) GetTableName() (string, error) {
+	return to.GetTableNameContext(context.Background())
+}
+
+// GetTableNameContext 获取分表名，分表不存在时自动创建
+func (to *TableOption) GetTableNameContext(ctx context.Context) (string, error) {
 	if to.err != nil {
 		return "", to.err
 	}
-	if !to.lock() {
-		log.Printf("sharding.GetTableName，分布式锁获取失败:\n[table:]%s\n[db:]%s\n", to.expect, to.db)
-		return "", errors.New("sharding.GetTableName，分布式锁获取失败")
+	ctx, span := startSpan(ctx, to.tracerProvider, "sharding.GetTableName",
+		attrDB.String(to.db), attrPrimary.String(to.primary), attrShard.String(to.expect))
+	table, err := to.getTableName(ctx, span)
+	endSpan(span, err)
+	return table, err
+}
+
+func (to *TableOption) getTableName(ctx context.Context, span trace.Span) (string, error) {
+	var logger, observer = to.getLogger(), to.getObserver()
+	var expectKey = CacheKey(to.db, to.expect)
+	if to.getCache().Exists(ctx, expectKey) {
+		// 缓存发现分表已有信息
+		logger.DebugContext(ctx, "sharding.GetTableName，缓存命中", slog.String("table", to.expect))
+		observer.CacheHit(ctx, to.db, to.primary, to.expect)
+		span.SetAttributes(attrCacheHit.Bool(true))
+		return to.expect, nil
+	}
+	observer.CacheMiss(ctx, to.db, to.primary, to.expect)
+	span.SetAttributes(attrCacheHit.Bool(false))
+	lockCtx, lockSpan := startSpan(ctx, to.tracerProvider, "sharding.lock")
+	wait, attempts, ok := to.lock(lockCtx)
+	lockSpan.SetAttributes(attrWaitMs.Int64(wait.Milliseconds()), attrAttempts.Int(attempts))
+	if !ok {
+		endSpan(lockSpan, ErrLockTimeout)
+	} else {
+		lockSpan.End()
+	}
+	if !ok {
+		observer.LockFailed(ctx, to.db, to.primary, wait, attempts)
+		logger.ErrorContext(ctx, "sharding.GetTableName，分布式锁获取失败",
+			slog.String("table", to.expect), slog.Duration("lock_wait", wait), slog.Int("lock_attempts", attempts))
+		return "", ErrLockTimeout
+	}
+	defer to.unlock(ctx)
+	observer.LockAcquired(ctx, to.db, to.primary, wait, attempts)
+	logger.DebugContext(ctx, "sharding.GetTableName，分布式锁获取成功",
+		slog.String("table", to.expect), slog.Duration("lock_wait", wait), slog.Int("lock_attempts", attempts))
+	if to.getCache().Exists(ctx, expectKey) {
+		// 等锁期间其他请求已建表
+		return to.expect, nil
+	}
+	// 分表已存在（进程重启、缓存过期等）时不再建表，也不继承迁移记录、不登记，避免覆盖已有的迁移版本和登记状态
+	exists, err := tableExists(ctx, to.mysqlClient, to.db, to.expect)
+	if err != nil {
+		return "", err
 	}
-	defer to.unlock()
-	var expectKey = fmt.Sprintf("expect_%s_%s", to.db, to.expect)
-	if isExist, ok := cache.Load(expectKey); ok && isExist.(bool) {
-		// 内存发现分表已有信息
+	if exists {
+		logger.DebugContext(ctx, "sharding.GetTableName，分表已存在", slog.String("table", to.expect))
+		to.getCache().Store(ctx, expectKey)
 		return to.expect, nil
 	}
 	// 表不存在，初始建表结构，新建表
+	createSql, err := to.createSql(ctx)
+	if err != nil {
+		return "", err
+	}
+	var begin = time.Now()
+	ddlCtx, ddlSpan := startSpan(ctx, to.tracerProvider, "sharding.ddl", attrShard.String(to.expect), attrSQL.String(createSql))
+	_, err = to.mysqlClient.ExecContext(ddlCtx, createSql)
+	endSpan(ddlSpan, err)
+	observer.DDLExecuted(ctx, to.db, to.primary, to.expect, time.Since(begin), err)
+	if err != nil {
+		logger.ErrorContext(ctx, "sharding.GetTableName，创建新表报错", slog.String("table", to.expect), slog.String("sql", createSql), slog.Any("err", err))
+		return "", &DDLError{SQL: createSql, Cause: err}
+	}
+	logger.InfoContext(ctx, "sharding.GetTableName，创建新表", slog.String("table", to.expect), slog.String("sql", createSql))
+	// 新分表与基础表结构一致，继承基础表的迁移版本
+	if err = inheritMigrations(ctx, to.mysqlClient, to.db, to.primary, to.expect); err != nil {
+		logger.WarnContext(ctx, "sharding.GetTableName，迁移记录继承失败", slog.String("table", to.expect), slog.Any("err", err))
+	}
+	// 按模板新建的分表已包含模板对应的迁移，基础表不存在或没有迁移记录时也不会被 Migrate 重复执行
+	if to.schema != nil {
+		if err = recordMigrations(ctx, to.mysqlClient, to.db, to.primary, to.expect, to.schemaMigrations); err != nil {
+			logger.WarnContext(ctx, "sharding.GetTableName，迁移记录写入失败", slog.String("table", to.expect), slog.Any("err", err))
+		}
+	}
+	if to.registry != nil {
+		var start, end = to.t.Bucket(to.thisTime)
+		if err = to.registry.Register(ctx, to.primary, to.expect, start, end); err != nil {
+			logger.WarnContext(ctx, "sharding.GetTableName，分表登记失败", slog.String("table", to.expect), slog.Any("err", err))
+		}
+	}
+	to.getCache().Store(ctx, expectKey)
+	return to.expect, nil
+}
+
+// Plan 计算获取分表名时将要执行的建表语句，不加锁、不建表、不写缓存，分表已存在时返回空计划
+func (to *TableOption) Plan(ctx context.Context) (*Plan, error) {
+	if to.err != nil {
+		return nil, to.err
+	}
+	var plan = new(Plan)
+	exists, err := tableExists(ctx, to.mysqlClient, to.db, to.expect)
+	if err != nil || exists {
+		return plan, err
+	}
+	createSql, err := to.createSql(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var reason = fmt.Sprintf("分表不存在，复制基础表 %s 结构创建", to.primary)
+	if to.schema != nil {
+		reason = "分表不存在，按建表模板创建"
+	}
+	plan.add(to.expect, createSql, reason)
+	return plan, nil
+}
+
+// Do 获取分表名后执行 fn，fn 返回 mysql 1146 表不存在（分表被其他实例删除，缓存未及时失效）时，
+// 删除缓存并重新建表，再执行一次 fn
+func (to *TableOption) Do(ctx context.Context, fn func(table string) error) error {
+	table, err := to.GetTableNameContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err = fn(table); !isNoSuchTable(err) {
+		return err
+	}
+	to.getLogger().WarnContext(ctx, "sharding.Do，分表不存在，重新建表", slog.String("table", to.expect))
+	to.Invalidate(ctx)
+	if table, err = to.GetTableNameContext(ctx); err != nil {
+		return err
+	}
+	return fn(table)
+}
+
+// Target 分表所在的数据库，未设置 Placement 时为 MysqlClient、DBName
+func (to *TableOption) Target() *Target {
+	if to.err != nil {
+		return nil
+	}
+	if to.target != nil {
+		return to.target
+	}
+	return &Target{Client: to.mysqlClient, DB: to.db}
+}
+
+// Invalidate 删除当前分表的存在性缓存
+func (to *TableOption) Invalidate(ctx context.Context) {
+	if to.err != nil {
+		return
+	}
+	to.getCache().Invalidate(ctx, CacheKey(to.db, to.expect))
+}
+
+// getLogger 日志默认使用 slog.Default()，统一带上库名、基础表名
+func (to *TableOption) getLogger() *slog.Logger {
+	var logger = to.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With(slog.String("db", to.db), slog.String("primary", to.primary))
+}
+
+func (to *TableOption) getObserver() Observer {
+	if to.observer != nil {
+		return to.observer
+	}
+	return NopObserver{}
+}
+
+func (to *TableOption) getCache() Cache {
+	if to.cache != nil {
+		return to.cache
+	}
+	return DefaultCache()
+}
+
+// createSql 分表建表语句，设置了建表模板的使用模板渲染，否则复制基础表结构
+func (to *TableOption) createSql(ctx context.Context) (string, error) {
+	if to.schema != nil {
+		var start, end = to.t.Bucket(to.thisTime)
+		createSql, err := renderSchema(to.schema, &SchemaData{DB: to.db, Primary: to.primary, Table: to.expect, Shard: to.keySuffix, Start: start, End: end})
+		if err != nil {
+			to.getLogger().ErrorContext(ctx, "sharding.GetTableName，建表模板渲染失败", slog.String("table", to.expect), slog.Any("err", err))
+			return "", err
+		}
+		return createSql, nil
+	}
 	showCreateSql := fmt.Sprintf("SHOW CREATE TABLE `%s`.`%s`", to.db, to.primary)
 	var showTableName, createSql string
-	err := to.mysqlClient.QueryRow(showCreateSql).Scan(&showTableName, &createSql)
+	err := to.mysqlClient.QueryRowContext(ctx, showCreateSql).Scan(&showTableName, &createSql)
 	if err != nil {
-		log.Printf("sharding.GetTableName，建表信息获取失败:\n[sql:]%s\n[table:]%s\n[db:]%s\n[err:]%v\n", showCreateSql, to.primary, to.db, err)
+		to.getLogger().ErrorContext(ctx, "sharding.GetTableName，建表信息获取失败", slog.String("sql", showCreateSql), slog.Any("err", err))
+		if isNoSuchTable(err) {
+			return "", fmt.Errorf("%w：%s.%s，%w", ErrBaseTableMissing, to.db, to.primary, err)
+		}
 		return "", err
 	}
-	createSql = strings.ReplaceAll(createSql, fmt.Sprintf("CREATE TABLE `%s`", to.primary), fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`.`%s`", to.db, to.expect))
-	_, err = to.mysqlClient.Exec(createSql)
+	createSql, err = TransformDDL(createSql, to.db, to.primary, to.expect, to.ddl)
 	if err != nil {
-		log.Printf("sharding.GetTableName，创建新表报错:\n[sql:]%s\n[err:]%v\n", createSql, err)
+		to.getLogger().ErrorContext(ctx, "sharding.GetTableName，建表语句改写失败", slog.String("table", to.expect), slog.Any("err", err))
 		return "", err
 	}
-	cache.Store(expectKey, true)
-	return to.expect, nil
+	return createSql, nil
 }
 
-func (to *TableOption) lock() bool {
+// lock 获取建表分布式锁，返回等待时长和尝试次数
+func (to *TableOption) lock(ctx context.Context) (time.Duration, int, bool) {
+	var begin = time.Now()
 	// count：重试计数器；retry：重试次数
 	var count, retry = 1, 50
-	for !to.redisClient.SetNX(context.Background(), fmt.Sprintf("SHARDING_TABLE_LOCK_%s_%s", to.db, to.primary), 1234, 5*time.Second).Val() {
+	for !to.redisClient.SetNX(ctx, fmt.Sprintf("SHARDING_TABLE_LOCK_%s_%s", to.db, to.primary), 1234, 5*time.Second).Val() {
 		if count > retry {
-			return false
+			return time.Since(begin), count, false
 		}
 
 		count++
 		time.Sleep(100 * time.Millisecond)
 	}
-	return true
+	return time.Since(begin), count, true
 }
 
-func (to *TableOption) unlock() {
-	to.redisClient.Del(context.Background(), fmt.Sprintf("SHARDING_TABLE_LOCK_%s_%s", to.db, to.primary))
+func (to *TableOption) unlock(ctx context.Context) {
+	to.redisClient.Del(context.WithoutCancel(ctx), fmt.Sprintf("SHARDING_TABLE_LOCK_%s_%s", to.db, to.primary))
 }
@@ -0,0 +1,117 @@
+package tester
+
+import (
+	"context"
+	"database/sql"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestBulkValidation 测试批量删除、更新的参数校验
+func TestBulkValidation(t *testing.T) {
+	ctx := context.Background()
+	mysqlClient, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/test")
+	require.NoError(t, err)
+	defer mysqlClient.Close()
+	start := time.Date(2025, 8, 20, 0, 0, 0, 0, time.Local)
+
+	_, err = sharding.DeleteRange(ctx, sharding.BulkBuilder().DBName("test").Primary("logs").Start(start).End(start.AddDate(0, 0, 1)))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "MysqlClient"})
+	_, err = sharding.DeleteRange(ctx, sharding.BulkBuilder().MysqlClient(mysqlClient).DBName("test").Primary("logs").Start(start))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "End"})
+	_, err = sharding.DeleteRange(ctx, sharding.BulkBuilder().MysqlClient(mysqlClient).DBName("test").Primary("logs").Start(start).End(start))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "End"})
+	_, err = sharding.UpdateRange(ctx, sharding.BulkBuilder().MysqlClient(mysqlClient).DBName("test").Primary("logs").Start(start).End(start.AddDate(0, 0, 1)), " ")
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Set"})
+}
+
+// TestBulk 测试按时间范围批量删除、更新
+func TestBulk(t *testing.T) {
+	mysqlClient := setupMysql(t)
+	ctx := context.Background()
+
+	day := time.Date(2025, 8, 20, 0, 0, 0, 0, time.Local)
+	for i, table := range []string{"bulk_logs_20250820", "bulk_logs_20250821", "bulk_logs_20250822"} {
+		_, err := mysqlClient.Exec("CREATE TABLE `test`.`" + table + "` (`id` INT PRIMARY KEY, `status` INT NOT NULL, `created_at` DATETIME NOT NULL)")
+		require.NoError(t, err)
+		var base = day.AddDate(0, 0, i)
+		for hour := range 24 {
+			_, err = mysqlClient.Exec("INSERT INTO `test`.`"+table+"` VALUES (?, 0, ?)", hour+1, base.Add(time.Duration(hour)*time.Hour).Format(time.DateTime))
+			require.NoError(t, err)
+		}
+	}
+	builder := func() *sharding.BulkOptionsBuilder {
+		return sharding.BulkBuilder().
+			MysqlClient(mysqlClient).
+			DBName("test").
+			Primary("bulk_logs").
+			TimeColumn("created_at").
+			BatchSize(5)
+	}
+	count := func(table string) int {
+		var n int
+		require.NoError(t, mysqlClient.QueryRow("SELECT COUNT(*) FROM `test`.`"+table+"`").Scan(&n))
+		return n
+	}
+
+	// 20 日 12 点到 22 日 0 点：20 日部分删除，21 日整体清空，22 日不在范围内
+	start, end := day.Add(12*time.Hour), day.AddDate(0, 0, 2)
+	result, err := sharding.DeleteRange(ctx, builder().Start(start).End(end).DryRun())
+	require.NoError(t, err)
+	require.Len(t, result.Plan.Statements, 2)
+	require.Equal(t, "TRUNCATE TABLE `test`.`bulk_logs_20250821`", result.Plan.Statements[1].SQL)
+	require.Equal(t, 24, count("bulk_logs_20250820"))
+
+	var progress []sharding.BulkProgress
+	result, err = sharding.DeleteRange(ctx, builder().Start(start).End(end).Progress(func(p sharding.BulkProgress) {
+		progress = append(progress, p)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, int64(12), result.Rows["bulk_logs_20250820"])
+	require.Equal(t, []string{"bulk_logs_20250821"}, result.Truncated)
+	require.Equal(t, 12, count("bulk_logs_20250820"))
+	require.Equal(t, 0, count("bulk_logs_20250821"))
+	require.Equal(t, 24, count("bulk_logs_20250822"))
+	// 20 日 3 批（5、5、2），21 日 1 次
+	require.Len(t, progress, 4)
+	require.True(t, progress[2].Done)
+	require.Equal(t, sharding.BulkTruncate, progress[3].Action)
+
+	// 条件更新，按 id 分段
+	result, err = sharding.UpdateRange(ctx, builder().Start(day).End(day.AddDate(0, 0, 3)).Where("`id` % 2 = ?", 0), "`status` = `status` + ?", 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(18), result.Total())
+	var updated int
+	require.NoError(t, mysqlClient.QueryRow("SELECT COUNT(*) FROM `test`.`bulk_logs_20250822` WHERE `status` = 1").Scan(&updated))
+	require.Equal(t, 12, updated)
+
+	// 超过 2^53 的雪花 ID 分段，每行恰好更新一次
+	_, err = mysqlClient.Exec("CREATE TABLE `test`.`bulk_ids_20250820` (`id` BIGINT PRIMARY KEY, `status` INT NOT NULL, `created_at` DATETIME NOT NULL)")
+	require.NoError(t, err)
+	const snowflake = int64(1) << 62
+	for i := range int64(7) {
+		_, err = mysqlClient.Exec("INSERT INTO `test`.`bulk_ids_20250820` VALUES (?, 0, ?)", snowflake+i, day.Format(time.DateTime))
+		require.NoError(t, err)
+	}
+	result, err = sharding.UpdateRange(ctx, builder().Primary("bulk_ids").Start(day).End(day.AddDate(0, 0, 1)).BatchSize(2), "`status` = `status` + ?", 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), result.Total())
+	require.NoError(t, mysqlClient.QueryRow("SELECT COUNT(*) FROM `test`.`bulk_ids_20250820` WHERE `status` = 1").Scan(&updated))
+	require.Equal(t, 7, updated)
+
+	// 整体删除，登记表在其他库时分表仍在 DBName 中删除
+	_, err = mysqlClient.Exec("CREATE DATABASE IF NOT EXISTS `bulk_registry`")
+	require.NoError(t, err)
+	registry := sharding.NewRegistry(mysqlClient, "bulk_registry")
+	result, err = sharding.DeleteRange(ctx, builder().Start(day).End(day.AddDate(0, 0, 3)).Drop().Registry(registry))
+	require.NoError(t, err)
+	require.Len(t, result.Dropped, 3)
+	shards, err := sharding.ListShards(ctx, mysqlClient, "test", "bulk_logs")
+	require.NoError(t, err)
+	require.Empty(t, shards)
+	dropped, err := registry.List(ctx, "bulk_logs", sharding.StatusDropped)
+	require.NoError(t, err)
+	require.Len(t, dropped, 3)
+}
@@ -0,0 +1,52 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestMemoryCache 测试进程内缓存的过期和失效
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	key := sharding.CacheKey("test", "user_logs_20250821")
+	require.Equal(t, "expect_test_user_logs_20250821", key)
+
+	t.Run("不过期", func(t *testing.T) {
+		c := sharding.NewMemoryCache(0)
+		require.False(t, c.Exists(ctx, key))
+		c.Store(ctx, key)
+		require.True(t, c.Exists(ctx, key))
+		c.Invalidate(ctx, key)
+		require.False(t, c.Exists(ctx, key))
+	})
+
+	t.Run("过期", func(t *testing.T) {
+		c := sharding.NewMemoryCache(20 * time.Millisecond)
+		c.Store(ctx, key)
+		require.True(t, c.Exists(ctx, key))
+		require.Eventually(t, func() bool { return !c.Exists(ctx, key) }, time.Second, 10*time.Millisecond)
+	})
+}
+
+// TestRedisCache 测试两级缓存在多个实例间共享和失效广播
+func TestRedisCache(t *testing.T) {
+	redisClient := setupRedis(t)
+	ctx := context.Background()
+	key := sharding.CacheKey("test", "user_logs_20250821")
+
+	instance1 := sharding.NewRedisCache(redisClient, time.Minute)
+	defer instance1.Close()
+	instance2 := sharding.NewRedisCache(redisClient, time.Minute)
+	defer instance2.Close()
+
+	instance1.Store(ctx, key)
+	// 实例2从 redis 读取，并写入本地缓存
+	require.True(t, instance2.Exists(ctx, key))
+
+	// 实例1删除后广播，实例2的本地缓存也失效
+	instance1.Invalidate(ctx, key)
+	require.Eventually(t, func() bool { return !instance2.Exists(ctx, key) }, 5*time.Second, 50*time.Millisecond)
+}
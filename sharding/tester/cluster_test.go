@@ -0,0 +1,94 @@
+package tester
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestCluster 测试读写分离的从库选择和最近分表强制主库
+func TestCluster(t *testing.T) {
+	ctx := context.Background()
+	open := func() *sql.DB {
+		client, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/test")
+		require.NoError(t, err)
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+	writer, reader1, reader2, reader3 := open(), open(), open(), open()
+
+	t.Run("没有从库", func(t *testing.T) {
+		cluster := sharding.NewCluster(writer)
+		require.Same(t, writer, cluster.Reader(ctx))
+	})
+
+	t.Run("轮询", func(t *testing.T) {
+		cluster := sharding.NewCluster(writer).Readers(reader1, reader2)
+		require.Same(t, reader1, cluster.Reader(ctx))
+		require.Same(t, reader2, cluster.Reader(ctx))
+		require.Same(t, reader1, cluster.Reader(ctx))
+		require.Same(t, writer, cluster.Writer())
+	})
+
+	t.Run("最小延迟", func(t *testing.T) {
+		var lags = map[*sql.DB]time.Duration{reader1: 3 * time.Second, reader2: time.Second}
+		var calls int
+		cluster := sharding.NewCluster(writer).
+			Readers(reader1, reader2, reader3).
+			Policy(sharding.LeastLag).
+			LagInterval(time.Minute).
+			Lag(func(_ context.Context, client *sql.DB) (time.Duration, error) {
+				calls++
+				if lag, ok := lags[client]; ok {
+					return lag, nil
+				}
+				return 0, errors.New("复制已中断")
+			})
+		require.Same(t, reader2, cluster.Reader(ctx))
+		// 采样间隔内复用结果
+		lags[reader1] = 0
+		require.Same(t, reader2, cluster.Reader(ctx))
+		require.Equal(t, 3, calls)
+
+		// 所有从库都不可用时使用主库
+		cluster = sharding.NewCluster(writer).
+			Readers(reader1).
+			Policy(sharding.LeastLag).
+			Lag(func(context.Context, *sql.DB) (time.Duration, error) { return 0, errors.New("复制已中断") })
+		require.Same(t, writer, cluster.Reader(ctx))
+	})
+
+	t.Run("调用方取消不影响采样", func(t *testing.T) {
+		cluster := sharding.NewCluster(writer).
+			Readers(reader1).
+			Policy(sharding.LeastLag).
+			LagInterval(time.Minute).
+			Lag(func(ctx context.Context, _ *sql.DB) (time.Duration, error) {
+				return 0, ctx.Err()
+			})
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		require.Same(t, reader1, cluster.Reader(cancelled))
+		require.Same(t, reader1, cluster.Reader(ctx))
+	})
+
+	t.Run("Cluster 为 nil", func(t *testing.T) {
+		_, err := sharding.New(offlineBuilder(t).Cluster(nil)).GetTableName()
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Cluster"})
+	})
+
+	t.Run("最近分表使用主库", func(t *testing.T) {
+		cluster := sharding.NewCluster(writer).Readers(reader1).ForceWriter(time.Hour)
+		now := time.Now()
+		today := &sharding.ParamsResult{TableName: "logs_today", Start: now.Truncate(time.Hour), End: now.Add(time.Hour)}
+		history := &sharding.ParamsResult{TableName: "logs_history", Start: now.AddDate(0, 0, -3), End: now.AddDate(0, 0, -2)}
+		boundary := &sharding.ParamsResult{TableName: "logs_boundary", Start: now.Add(-25 * time.Hour), End: now.Add(-30 * time.Minute)}
+		require.Same(t, writer, cluster.Client(ctx, today))
+		require.Same(t, writer, cluster.Client(ctx, boundary))
+		require.Same(t, reader1, cluster.Client(ctx, history))
+	})
+}
@@ -0,0 +1,229 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestTieredParams 测试分层分表按分表距今时间选择分表类型
+func TestTieredParams(t *testing.T) {
+	now := time.Date(2025, 8, 21, 15, 30, 0, 0, time.Local)
+	tiers := []sharding.Tier{
+		{Type: sharding.Hour, Age: 48 * time.Hour},
+		{Type: sharding.Day, Age: 30 * 24 * time.Hour},
+		{Type: sharding.Month},
+	}
+	builder := func(start, end time.Time) *sharding.ParamsOptionsBuilder {
+		return sharding.ParamsBuilder().Primary("logs").Start(start).End(end).Tiers(tiers...).Now(now)
+	}
+
+	// 小时分表保留到 8 月 19 日 0 点，天分表保留到 7 月 1 日
+	params, err := sharding.Params(builder(time.Date(2025, 6, 15, 0, 0, 0, 0, time.Local), time.Date(2025, 8, 19, 2, 0, 0, 0, time.Local)))
+	require.NoError(t, err)
+	require.Len(t, params, 1+31+18+2)
+	require.Equal(t, "logs_202506", params[0].TableName)
+	require.Equal(t, sharding.Month, params[0].Type)
+	require.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.Local), params[0].End)
+	require.Equal(t, "logs_20250701", params[1].TableName)
+	require.Equal(t, "logs_20250818", params[49].TableName)
+	require.Equal(t, sharding.Day, params[49].Type)
+	require.Equal(t, "logs_2025081900", params[50].TableName)
+	require.Equal(t, "logs_2025081901", params[51].TableName)
+	require.Equal(t, sharding.Hour, params[51].Type)
+	for i := 1; i < len(params); i++ {
+		require.Equal(t, params[i-1].End, params[i].Start)
+		require.False(t, params[i-1].IsEndClose)
+	}
+
+	// 只在一层内
+	params, err = sharding.Params(builder(time.Date(2025, 8, 20, 10, 0, 0, 0, time.Local), time.Date(2025, 8, 20, 12, 0, 0, 0, time.Local)).IsEndClose(true))
+	require.NoError(t, err)
+	require.Len(t, params, 3)
+	require.True(t, params[2].IsEndClose)
+	at := time.Date(2025, 7, 10, 8, 0, 0, 0, time.Local)
+	params, err = sharding.Params(builder(at, at))
+	require.NoError(t, err)
+	require.Len(t, params, 1)
+	require.Equal(t, "logs_20250710", params[0].TableName)
+
+	// 结束时间在分界上，闭区间时包含细一层的第一张分表
+	boundary := time.Date(2025, 8, 19, 0, 0, 0, 0, time.Local)
+	params, err = sharding.Params(builder(boundary.Add(-time.Hour), boundary))
+	require.NoError(t, err)
+	require.Len(t, params, 1)
+	require.Equal(t, "logs_20250818", params[0].TableName)
+	params, err = sharding.Params(builder(boundary.Add(-time.Hour), boundary).IsEndClose(true))
+	require.NoError(t, err)
+	require.Len(t, params, 2)
+	require.Equal(t, "logs_2025081900", params[1].TableName)
+
+	_, err = sharding.Params(sharding.ParamsBuilder().Primary("logs").Start(at).End(at).Tiers(sharding.Tier{Type: sharding.Day}))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Tiers"})
+	_, err = sharding.Params(sharding.ParamsBuilder().Primary("logs").Start(at).End(at).Tiers(
+		sharding.Tier{Type: sharding.Day, Age: time.Hour}, sharding.Tier{Type: sharding.Hour}))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Tiers"})
+}
+
+// TestCompactValidation 测试分表合并参数校验
+func TestCompactValidation(t *testing.T) {
+	ctx := context.Background()
+	_, err := sharding.Compact(ctx, sharding.CompactBuilder())
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Table"})
+	_, err = sharding.Compact(ctx, sharding.CompactBuilder().Table(offlineBuilder(t)).From(sharding.Hour).To(sharding.Day).Before(time.Now()))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "TimeColumn"})
+	_, err = sharding.Compact(ctx, sharding.CompactBuilder().Table(offlineBuilder(t)).TimeColumn("created_at").From(sharding.Day).To(sharding.Hour).Before(time.Now()))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "To"})
+	_, err = sharding.Compact(ctx, sharding.CompactBuilder().Table(offlineBuilder(t)).TimeColumn("created_at").From(sharding.Hour).To(sharding.Day))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Before"})
+}
+
+// TestCompact 测试小时分表合并为天分表
+func TestCompact(t *testing.T) {
+	mysqlClient := setupMysql(t)
+	redisClient := setupRedis(t)
+	ctx := context.Background()
+
+	_, err := mysqlClient.Exec("CREATE TABLE `test`.`compact_logs` (`id` BIGINT PRIMARY KEY, `note` VARCHAR(16) NULL, `created_at` DATETIME NOT NULL)")
+	require.NoError(t, err)
+	day := time.Date(2025, 8, 19, 0, 0, 0, 0, time.Local)
+	var id int
+	// 19 日 3 张小时分表，20 日 1 张小时分表
+	for _, hour := range []time.Time{day, day.Add(time.Hour), day.Add(5 * time.Hour), day.AddDate(0, 0, 1)} {
+		table := "compact_logs_" + hour.Format("2006010215")
+		_, err = mysqlClient.Exec("CREATE TABLE `test`.`" + table + "` LIKE `test`.`compact_logs`")
+		require.NoError(t, err)
+		for minute := range 5 {
+			id++
+			var note any = "n"
+			if minute == 0 {
+				note = nil
+			}
+			_, err = mysqlClient.Exec("INSERT INTO `test`.`"+table+"` VALUES (?, ?, ?)", id, note, hour.Add(time.Duration(minute)*time.Minute).Format(time.DateTime))
+			require.NoError(t, err)
+		}
+	}
+	builder := func() *sharding.CompactOptionsBuilder {
+		return sharding.CompactBuilder().
+			Table(sharding.TableBuilder().
+				MysqlClient(mysqlClient).
+				RedisClient(redisClient).
+				DBName("test").
+				Primary("compact_logs").
+				Cache(sharding.NewMemoryCache(0))).
+			From(sharding.Hour).
+			To(sharding.Day).
+			Before(day.AddDate(0, 0, 1).Add(12 * time.Hour)).
+			TimeColumn("created_at").
+			BatchSize(2)
+	}
+
+	result, err := sharding.Compact(ctx, builder().DryRun())
+	require.NoError(t, err)
+	// 建表 + 3 张源分表各复制、删除一次
+	require.Len(t, result.Plan.Statements, 7)
+
+	// 模拟上次合并中断：目标分表中已有部分数据
+	_, err = mysqlClient.Exec("CREATE TABLE `test`.`compact_logs_20250819` LIKE `test`.`compact_logs`")
+	require.NoError(t, err)
+	_, err = mysqlClient.Exec("INSERT INTO `test`.`compact_logs_20250819` SELECT * FROM `test`.`compact_logs_2025081900` LIMIT 3")
+	require.NoError(t, err)
+
+	result, err = sharding.Compact(ctx, builder())
+	require.NoError(t, err)
+	require.Equal(t, []string{"compact_logs_20250819"}, result.Targets)
+	require.Len(t, result.Dropped, 3)
+	// 已复制的 3 行跳过
+	require.Equal(t, int64(12), result.Rows["compact_logs_20250819"])
+	var count int
+	require.NoError(t, mysqlClient.QueryRow("SELECT COUNT(*) FROM `test`.`compact_logs_20250819`").Scan(&count))
+	require.Equal(t, 15, count)
+
+	// 源分表删除后迟到的写入重新建出源分表：只补充新行，已合并的数据保留
+	_, err = mysqlClient.Exec("CREATE TABLE `test`.`compact_logs_2025081900` LIKE `test`.`compact_logs`")
+	require.NoError(t, err)
+	_, err = mysqlClient.Exec("INSERT INTO `test`.`compact_logs_2025081900` VALUES (100, 'late', ?)", day.Add(30*time.Minute).Format(time.DateTime))
+	require.NoError(t, err)
+	result, err = sharding.Compact(ctx, builder())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Rows["compact_logs_20250819"])
+	require.Equal(t, []string{"compact_logs_2025081900"}, result.Dropped)
+	require.NoError(t, mysqlClient.QueryRow("SELECT COUNT(*) FROM `test`.`compact_logs_20250819`").Scan(&count))
+	require.Equal(t, 16, count)
+
+	// 迟到的行与已合并的行 key 相同、内容不同时校验失败，两边数据都保留
+	_, err = mysqlClient.Exec("CREATE TABLE `test`.`compact_logs_2025081900` LIKE `test`.`compact_logs`")
+	require.NoError(t, err)
+	_, err = mysqlClient.Exec("INSERT INTO `test`.`compact_logs_2025081900` VALUES (1, 'conflict', ?)", day.Format(time.DateTime))
+	require.NoError(t, err)
+	_, err = sharding.Compact(ctx, builder())
+	require.ErrorIs(t, err, sharding.ErrChecksumMismatch)
+	require.NoError(t, mysqlClient.QueryRow("SELECT COUNT(*) FROM `test`.`compact_logs_20250819`").Scan(&count))
+	require.Equal(t, 16, count)
+	_, err = mysqlClient.Exec("DROP TABLE `test`.`compact_logs_2025081900`")
+	require.NoError(t, err)
+	shards, err := sharding.ListShards(ctx, mysqlClient, "test", "compact_logs")
+	require.NoError(t, err)
+	require.Len(t, shards, 2)
+	require.Equal(t, "compact_logs_20250819", shards[0].Table)
+	require.Equal(t, "compact_logs_2025082000", shards[1].Table)
+}
+
+// TestCompactTiers 测试分层合并在分界到达前提前复制，Params 改查目标分表后才删除源分表
+func TestCompactTiers(t *testing.T) {
+	mysqlClient := setupMysql(t)
+	redisClient := setupRedis(t)
+	ctx := context.Background()
+
+	_, err := mysqlClient.Exec("CREATE TABLE `test`.`tier_logs` (`id` BIGINT PRIMARY KEY, `created_at` DATETIME NOT NULL)")
+	require.NoError(t, err)
+	day := time.Date(2025, 8, 19, 0, 0, 0, 0, time.Local)
+	for i, hour := range []time.Time{day.Add(time.Hour), day.Add(20 * time.Hour)} {
+		table := "tier_logs_" + hour.Format("2006010215")
+		_, err = mysqlClient.Exec("CREATE TABLE `test`.`" + table + "` LIKE `test`.`tier_logs`")
+		require.NoError(t, err)
+		_, err = mysqlClient.Exec("INSERT INTO `test`.`"+table+"` VALUES (?, ?)", i+1, hour.Format(time.DateTime))
+		require.NoError(t, err)
+	}
+	tiers := []sharding.Tier{{Type: sharding.Hour, Age: 48 * time.Hour}, {Type: sharding.Day}}
+	compact := func(now time.Time) *sharding.CompactResult {
+		result, err := sharding.Compact(ctx, sharding.CompactBuilder().
+			Table(sharding.TableBuilder().
+				MysqlClient(mysqlClient).
+				RedisClient(redisClient).
+				DBName("test").
+				Primary("tier_logs").
+				Cache(sharding.NewMemoryCache(0))).
+			Tiers(tiers...).
+			Now(now).
+			TimeColumn("created_at"))
+		require.NoError(t, err)
+		return result
+	}
+	route := func(now time.Time) string {
+		params, err := sharding.Params(sharding.ParamsBuilder().Primary("tier_logs").Start(day.Add(time.Hour)).End(day.Add(time.Hour)).IsEndClose(true).Tiers(tiers...).Now(now))
+		require.NoError(t, err)
+		require.Len(t, params, 1)
+		return params[0].TableName
+	}
+
+	// 分界到达前 1 小时内：目标分表已复制，源分表保留，Params 仍查询小时分表
+	before := day.AddDate(0, 0, 2).Add(23*time.Hour + 30*time.Minute)
+	result := compact(before)
+	require.Equal(t, []string{"tier_logs_20250819"}, result.Targets)
+	require.Equal(t, int64(2), result.Rows["tier_logs_20250819"])
+	require.Empty(t, result.Dropped)
+	require.Equal(t, "tier_logs_2025081901", route(before))
+
+	// 分界到达后：Params 查询天分表，数据已在其中，源分表校验一致后删除
+	after := before.Add(time.Hour)
+	require.Equal(t, "tier_logs_20250819", route(after))
+	result = compact(after)
+	require.Equal(t, []string{"tier_logs_2025081901", "tier_logs_2025081920"}, result.Dropped)
+	require.Zero(t, result.Rows["tier_logs_20250819"])
+	var count int
+	require.NoError(t, mysqlClient.QueryRow("SELECT COUNT(*) FROM `test`.`tier_logs_20250819`").Scan(&count))
+	require.Equal(t, 2, count)
+}
@@ -0,0 +1,88 @@
+package tester
+
+import (
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+const showCreateOrders = "CREATE TABLE `orders` (\n" +
+	"  `id` bigint unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `user_id` bigint unsigned NOT NULL,\n" +
+	"  `parent_id` bigint unsigned DEFAULT NULL,\n" +
+	"  `amount` int NOT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `fk_user` (`user_id`),\n" +
+	"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`),\n" +
+	"  CONSTRAINT `orders_ibfk_2` FOREIGN KEY (`parent_id`) REFERENCES `orders` (`id`),\n" +
+	"  CONSTRAINT `orders_chk_1` CHECK ((`amount` > 0))\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=10086 DEFAULT CHARSET=utf8mb4\n" +
+	"/*!50100 PARTITION BY HASH (`id`)\n" +
+	"PARTITIONS 4 */"
+
+// TestTransformDDL 测试基础表建表语句改写成分表建表语句
+func TestTransformDDL(t *testing.T) {
+	t.Run("默认规则", func(t *testing.T) {
+		ddl, err := sharding.TransformDDL(showCreateOrders, "test", "orders", "orders_202508", nil)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(ddl, "CREATE TABLE IF NOT EXISTS `test`.`orders_202508` (\n"))
+		require.NotContains(t, ddl, "AUTO_INCREMENT=")
+		require.Contains(t, ddl, "`id` bigint unsigned NOT NULL AUTO_INCREMENT,")
+		// 不含基础表名的约束追加后缀，包含基础表名的替换成分表名
+		require.Contains(t, ddl, "CONSTRAINT `fk_user_202508` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)")
+		require.Contains(t, ddl, "CONSTRAINT `orders_202508_ibfk_2` FOREIGN KEY (`parent_id`) REFERENCES `orders_202508` (`id`)")
+		require.Contains(t, ddl, "CONSTRAINT `orders_202508_chk_1` CHECK")
+		// 普通索引名不需要改
+		require.Contains(t, ddl, "KEY `fk_user` (`user_id`)")
+		require.Contains(t, ddl, "/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 4 */")
+	})
+
+	t.Run("自增起始值", func(t *testing.T) {
+		ddl, err := sharding.TransformDDL(showCreateOrders, "test", "orders", "orders_202508", sharding.DDLBuilder().AutoIncrement(5000))
+		require.NoError(t, err)
+		require.Contains(t, ddl, ") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 AUTO_INCREMENT=5000\n")
+	})
+
+	t.Run("保留自增和约束名", func(t *testing.T) {
+		ddl, err := sharding.TransformDDL(showCreateOrders, "test", "orders", "orders_202508", sharding.DDLBuilder().KeepAutoIncrement().KeepConstraintNames())
+		require.NoError(t, err)
+		require.Contains(t, ddl, "AUTO_INCREMENT=10086")
+		require.Contains(t, ddl, "CONSTRAINT `fk_user` FOREIGN KEY")
+		require.Contains(t, ddl, "CONSTRAINT `orders_ibfk_2` FOREIGN KEY (`parent_id`) REFERENCES `orders` (`id`)")
+	})
+
+	t.Run("去掉分区", func(t *testing.T) {
+		ddl, err := sharding.TransformDDL(showCreateOrders, "test", "orders", "orders_202508", sharding.DDLBuilder().StripPartition())
+		require.NoError(t, err)
+		require.NotContains(t, ddl, "PARTITION")
+		require.True(t, strings.HasSuffix(ddl, ") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"))
+	})
+
+	t.Run("改写分区", func(t *testing.T) {
+		ddl, err := sharding.TransformDDL(showCreateOrders, "test", "orders", "orders_202508", sharding.DDLBuilder().RewritePartition("PARTITION BY HASH(`user_id`) PARTITIONS 8"))
+		require.NoError(t, err)
+		require.True(t, strings.HasSuffix(ddl, ") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4\nPARTITION BY HASH(`user_id`) PARTITIONS 8"))
+
+		_, err = sharding.TransformDDL(showCreateOrders, "test", "orders", "orders_202508", sharding.DDLBuilder().RewritePartition(" "))
+		require.Error(t, err)
+	})
+
+	t.Run("约束名超长", func(t *testing.T) {
+		var primary = strings.Repeat("p", 50)
+		var createSql = "CREATE TABLE `" + primary + "` (\n" +
+			"  `id` int NOT NULL,\n" +
+			"  CONSTRAINT `" + primary + "_chk_1` CHECK ((`id` > 0))\n" +
+			") ENGINE=InnoDB"
+		ddl, err := sharding.TransformDDL(createSql, "test", primary, primary+"_2025082115", nil)
+		require.NoError(t, err)
+		var start = strings.Index(ddl, "CONSTRAINT `") + len("CONSTRAINT `")
+		var name = ddl[start : start+strings.Index(ddl[start:], "`")]
+		require.Len(t, name, 64)
+	})
+
+	t.Run("无法识别的语句", func(t *testing.T) {
+		_, err := sharding.TransformDDL("CREATE VIEW `v` AS SELECT 1", "test", "v", "v_2025", nil)
+		require.Error(t, err)
+	})
+}
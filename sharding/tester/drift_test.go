@@ -0,0 +1,109 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestParseShard 测试分表名解析
+func TestParseShard(t *testing.T) {
+	testCases := []struct {
+		table string
+		ok    bool
+		t     sharding.Type
+		start time.Time
+	}{
+		{"user_logs_2025082115", true, sharding.Hour, time.Date(2025, 8, 21, 15, 0, 0, 0, time.UTC)},
+		{"user_logs_20250821", true, sharding.Day, time.Date(2025, 8, 21, 0, 0, 0, 0, time.UTC)},
+		{"user_logs_202508", true, sharding.Month, time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{"user_logs_2025", true, sharding.Year, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"user_logs", false, 0, time.Time{}},
+		{"user_logs_backup", false, 0, time.Time{}},
+		{"user_logs_20251345", false, 0, time.Time{}},
+		{"user_logs_archive_2025", false, 0, time.Time{}},
+		{"user_logs_202508211", false, 0, time.Time{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.table, func(t *testing.T) {
+			shard, ok := sharding.ParseShard("user_logs", tc.table, time.UTC)
+			require.Equal(t, tc.ok, ok)
+			if !tc.ok {
+				return
+			}
+			require.Equal(t, tc.table, shard.Table)
+			require.Equal(t, tc.t, shard.Type)
+			require.Equal(t, tc.start, shard.Start)
+		})
+	}
+}
+
+// TestCheckDrift 测试分表结构漂移检测
+func TestCheckDrift(t *testing.T) {
+	mysqlClient, redisClient := setupMysql(t), setupRedis(t)
+	ctx := context.Background()
+
+	_, err := mysqlClient.Exec("CREATE TABLE `test`.`drift_logs` (" +
+		"`id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT," +
+		"`user_id` BIGINT UNSIGNED NOT NULL," +
+		"PRIMARY KEY (`id`)," +
+		"KEY `idx_user` (`user_id`)" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4")
+	require.NoError(t, err)
+
+	for _, day := range []int{20, 21} {
+		_, err = sharding.New(sharding.TableBuilder().
+			MysqlClient(mysqlClient).
+			RedisClient(redisClient).
+			DBName("test").
+			Primary("drift_logs").
+			ThisTime(time.Date(2025, 8, day, 10, 0, 0, 0, time.Local)).
+			Type(sharding.Day)).GetTableName()
+		require.NoError(t, err)
+	}
+
+	// 基础表新增列和索引，只有 20 号分表跟着改了一半
+	_, err = mysqlClient.Exec("ALTER TABLE `test`.`drift_logs` ADD COLUMN `ip` VARCHAR(64) NOT NULL DEFAULT '', ADD KEY `idx_ip` (`ip`)")
+	require.NoError(t, err)
+	_, err = mysqlClient.Exec("ALTER TABLE `test`.`drift_logs_20250820` ADD COLUMN `ip` VARCHAR(32) NOT NULL DEFAULT ''")
+	require.NoError(t, err)
+
+	reports, err := sharding.CheckDrift(ctx, mysqlClient, "test", "drift_logs")
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+
+	require.Equal(t, "drift_logs_20250820", reports[0].Table)
+	require.True(t, reports[0].HasDrift())
+	require.Len(t, reports[0].Columns, 1)
+	require.Equal(t, sharding.DriftChanged, reports[0].Columns[0].Kind)
+	require.Equal(t, "ip", reports[0].Columns[0].Name)
+	require.Len(t, reports[0].Indexes, 1)
+	require.Equal(t, sharding.DriftMissing, reports[0].Indexes[0].Kind)
+
+	require.Equal(t, "drift_logs_20250821", reports[1].Table)
+	require.Len(t, reports[1].Columns, 1)
+	require.Equal(t, sharding.DriftMissing, reports[1].Columns[0].Kind)
+	require.Empty(t, reports[1].Options)
+
+	// 没有默认值与默认值为字符串 'NULL' 不同
+	_, err = mysqlClient.Exec("ALTER TABLE `test`.`drift_logs_20250821` ADD COLUMN `ip` VARCHAR(64) NOT NULL DEFAULT ''")
+	require.NoError(t, err)
+	for _, table := range []string{"drift_logs", "drift_logs_20250820"} {
+		_, err = mysqlClient.Exec("ALTER TABLE `test`.`" + table + "` ADD COLUMN `memo` VARCHAR(8) NULL")
+		require.NoError(t, err)
+	}
+	_, err = mysqlClient.Exec("ALTER TABLE `test`.`drift_logs_20250821` ADD COLUMN `memo` VARCHAR(8) NULL DEFAULT 'NULL'")
+	require.NoError(t, err)
+	reports, err = sharding.CheckDrift(ctx, mysqlClient, "test", "drift_logs")
+	require.NoError(t, err)
+	require.Len(t, reports[1].Columns, 1)
+	require.Equal(t, sharding.DriftChanged, reports[1].Columns[0].Kind)
+	require.Equal(t, "memo", reports[1].Columns[0].Name)
+	require.NotContains(t, reports[1].Columns[0].Expect, "DEFAULT")
+	require.Contains(t, reports[1].Columns[0].Actual, "DEFAULT NULL")
+
+	_, err = sharding.CheckDrift(ctx, mysqlClient, "test", "drift_missing")
+	require.Error(t, err)
+}
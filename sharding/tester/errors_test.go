@@ -0,0 +1,53 @@
+package tester
+
+import (
+	"errors"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestErrors 测试导出错误可以使用 errors.Is / errors.As 区分
+func TestErrors(t *testing.T) {
+	t.Run("参数错误", func(t *testing.T) {
+		_, err := sharding.New(sharding.TableBuilder().DBName("test")).GetTableName()
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{})
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "MysqlClient"})
+		require.NotErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Primary"})
+
+		var invalid *sharding.ErrInvalidOption
+		_, err = sharding.Params(sharding.ParamsBuilder().Primary("user_logs").Start(time.Now()))
+		require.ErrorAs(t, err, &invalid)
+		require.Equal(t, "End", invalid.Field)
+		require.Contains(t, err.Error(), "end option is required")
+	})
+
+	t.Run("分表类型不识别", func(t *testing.T) {
+		_, err := sharding.New(offlineBuilder(t).Type(sharding.Type(99))).GetTableName()
+		require.ErrorIs(t, err, sharding.ErrUnknownType)
+
+		_, err = sharding.Params(sharding.ParamsBuilder().
+			Primary("user_logs").
+			Start(time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)).
+			End(time.Date(2025, 8, 2, 0, 0, 0, 0, time.UTC)).
+			Type(sharding.Type(99)))
+		require.ErrorIs(t, err, sharding.ErrUnknownType)
+		require.Contains(t, err.Error(), "WARNING：type unknown")
+	})
+
+	t.Run("建表模板错误", func(t *testing.T) {
+		_, err := sharding.New(offlineBuilder(t).Schema("CREATE TABLE {{if}}")).GetTableName()
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Schema"})
+	})
+
+	t.Run("DDL错误", func(t *testing.T) {
+		cause := errors.New("Error 1050: Table 'user_logs_20250821' already exists")
+		var err error = &sharding.DDLError{SQL: "CREATE TABLE `user_logs_20250821` (`id` INT)", Cause: cause}
+		require.ErrorIs(t, err, cause)
+		var ddlErr *sharding.DDLError
+		require.ErrorAs(t, err, &ddlErr)
+		require.Contains(t, ddlErr.SQL, "user_logs_20250821")
+		require.NotErrorIs(t, err, sharding.ErrLockTimeout)
+	})
+}
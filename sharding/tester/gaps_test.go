@@ -0,0 +1,64 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestFindGaps 测试时间范围内缺少的分表和不符合命名规则的表
+func TestFindGaps(t *testing.T) {
+	mysqlClient := setupMysql(t)
+	ctx := context.Background()
+
+	for _, table := range []string{"gap_logs", "gap_logs_2025082100", "gap_logs_2025082102", "gap_logs_20250821", "gap_logs_backup"} {
+		_, err := mysqlClient.Exec("CREATE TABLE `test`.`" + table + "` (`id` INT PRIMARY KEY)")
+		require.NoError(t, err)
+	}
+
+	report, err := sharding.FindGaps(ctx, mysqlClient, "test", "gap_logs", sharding.Hour,
+		time.Date(2025, 8, 21, 0, 0, 0, 0, time.Local), time.Date(2025, 8, 21, 3, 30, 0, 0, time.Local))
+	require.NoError(t, err)
+	require.True(t, report.HasGap())
+	require.Len(t, report.Missing, 2)
+	require.Equal(t, "gap_logs_2025082101", report.Missing[0].Table)
+	require.Equal(t, "gap_logs_2025082103", report.Missing[1].Table)
+	require.Equal(t, time.Date(2025, 8, 21, 3, 0, 0, 0, time.Local), report.Missing[1].Start)
+	require.Equal(t, time.Date(2025, 8, 21, 4, 0, 0, 0, time.Local), report.Missing[1].End)
+	require.Equal(t, []string{"gap_logs_20250821", "gap_logs_backup"}, report.Extra)
+
+	report, err = sharding.FindGaps(ctx, mysqlClient, "test", "gap_logs", sharding.Day,
+		time.Date(2025, 8, 21, 0, 0, 0, 0, time.Local), time.Date(2025, 8, 22, 0, 0, 0, 0, time.Local))
+	require.NoError(t, err)
+	require.False(t, report.HasGap())
+
+	// 分表粒度调整：调整前按月、9 月 15 日起按天，跨调整时间的月表不算多余，调整前的天表多余
+	for _, table := range []string{"gap_logs_202508", "gap_logs_202509", "gap_logs_20250910", "gap_logs_20250915", "gap_logs_20250917"} {
+		_, err = mysqlClient.Exec("CREATE TABLE `test`.`" + table + "` (`id` INT PRIMARY KEY)")
+		require.NoError(t, err)
+	}
+	schedule := []sharding.Cutover{{Type: sharding.Month}, {From: time.Date(2025, 9, 15, 0, 0, 0, 0, time.Local), Type: sharding.Day}}
+	report, err = sharding.FindGapsParams(ctx, mysqlClient, "test", sharding.ParamsBuilder().
+		Primary("gap_logs").
+		Start(time.Date(2025, 8, 1, 0, 0, 0, 0, time.Local)).
+		End(time.Date(2025, 9, 18, 0, 0, 0, 0, time.Local)).
+		Schedule(schedule...))
+	require.NoError(t, err)
+	require.Len(t, report.Missing, 1)
+	require.Equal(t, "gap_logs_20250916", report.Missing[0].Table)
+	require.Equal(t, []string{"gap_logs_2025082100", "gap_logs_2025082102", "gap_logs_20250821", "gap_logs_20250910", "gap_logs_backup"}, report.Extra)
+
+	// 分层分表：任一层的分表类型都不算多余，月表不属于任何一层
+	report, err = sharding.FindGapsParams(ctx, mysqlClient, "test", sharding.ParamsBuilder().
+		Primary("gap_logs").
+		Start(time.Date(2025, 8, 21, 0, 0, 0, 0, time.Local)).
+		End(time.Date(2025, 8, 21, 3, 0, 0, 0, time.Local)).
+		Tiers(sharding.Tier{Type: sharding.Hour, Age: 24 * time.Hour}, sharding.Tier{Type: sharding.Day}).
+		Now(time.Date(2025, 8, 21, 12, 0, 0, 0, time.Local)))
+	require.NoError(t, err)
+	require.Len(t, report.Missing, 1)
+	require.Equal(t, "gap_logs_2025082101", report.Missing[0].Table)
+	require.Equal(t, []string{"gap_logs_202508", "gap_logs_202509", "gap_logs_backup"}, report.Extra)
+}
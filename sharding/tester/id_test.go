@@ -0,0 +1,92 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestIDGenerator 测试雪花 ID 唯一、递增，并能由 ID 找到分表
+func TestIDGenerator(t *testing.T) {
+	_, err := sharding.NewIDGenerator(sharding.MaxIDNode + 1)
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Node"})
+
+	generator, err := sharding.NewIDGenerator(7)
+	require.NoError(t, err)
+
+	t.Run("并发唯一", func(t *testing.T) {
+		var (
+			mu  sync.Mutex
+			ids = make(map[int64]bool)
+			wg  sync.WaitGroup
+		)
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var last int64
+				for range 5000 {
+					id, err := generator.Next()
+					require.NoError(t, err)
+					require.Greater(t, id, last)
+					last = id
+					mu.Lock()
+					ids[id] = true
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		require.Len(t, ids, 40000)
+	})
+
+	t.Run("由 ID 找到分表", func(t *testing.T) {
+		before := time.Now().Truncate(time.Millisecond)
+		id, err := generator.Next()
+		require.NoError(t, err)
+		after := time.Now()
+		require.Equal(t, int64(7), sharding.IDNode(id))
+		created := sharding.IDTime(id)
+		require.False(t, created.Before(before))
+		require.False(t, created.After(after))
+
+		table, err := sharding.ShardForID(id, "orders", sharding.Day, nil)
+		require.NoError(t, err)
+		require.Equal(t, "orders_"+created.Format("20060102"), table)
+		params, err := sharding.Params(sharding.ParamsBuilder().Primary("orders").Start(created).End(created).Type(sharding.Day))
+		require.NoError(t, err)
+		require.Equal(t, params[0].TableName, table)
+
+		_, err = sharding.ShardForID(id, "orders", sharding.Type(99), nil)
+		require.ErrorIs(t, err, sharding.ErrUnknownType)
+		_, err = sharding.ShardForID(0, "orders", sharding.Day, nil)
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "ID"})
+
+		// 按其他时区写入的分表，分表名按同一时区计算
+		shanghai := time.FixedZone("CST", 8*3600)
+		table, err = sharding.ShardForID(id, "user_logs", sharding.Hour, shanghai)
+		require.NoError(t, err)
+		require.Equal(t, "user_logs_"+created.In(shanghai).Format("2006010215"), table)
+		c := sharding.NewMemoryCache(0)
+		c.Store(context.Background(), sharding.CacheKey("test", table))
+		tableName, err := sharding.New(offlineBuilder(t).ThisTime(created.In(shanghai)).Type(sharding.Hour).Cache(c)).GetTableName()
+		require.NoError(t, err)
+		require.Equal(t, table, tableName)
+	})
+
+	t.Run("自定义起点", func(t *testing.T) {
+		epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		custom, err := sharding.NewIDGenerator(1)
+		require.NoError(t, err)
+		custom.Epoch(epoch)
+		id, err := custom.Next()
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), custom.Time(id), time.Second)
+		table, err := custom.ShardForID(id, "orders", sharding.Month, nil)
+		require.NoError(t, err)
+		require.Equal(t, "orders_"+time.Now().Format("200601"), table)
+	})
+}
@@ -0,0 +1,194 @@
+package tester
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestKeyStrategy 测试取模、一致性哈希、范围查表分表策略
+func TestKeyStrategy(t *testing.T) {
+	t.Run("取模", func(t *testing.T) {
+		modulo := sharding.Modulo{N: 64}
+		for key, expect := range map[int64]string{0: "00", 7: "07", 64: "00", 130: "02", -1: "63"} {
+			suffix, err := modulo.Shard(key)
+			require.NoError(t, err)
+			require.Equal(t, expect, suffix, key)
+		}
+		require.Len(t, modulo.Shards(), 64)
+		require.Equal(t, "63", modulo.Shards()[63])
+		_, err := sharding.Modulo{}.Shard(1)
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Key"})
+
+		// 4、6、8、10 位后缀与按时间分表混淆，多补一位
+		suffix, err := sharding.Modulo{N: 10000}.Shard(2024)
+		require.NoError(t, err)
+		require.Equal(t, "02024", suffix)
+		suffix, err = sharding.Modulo{N: 1000}.Shard(7)
+		require.NoError(t, err)
+		require.Equal(t, "007", suffix)
+		_, ok := sharding.ParseShard("users", "users_"+suffix, time.Local)
+		require.False(t, ok)
+	})
+
+	t.Run("后缀与时间分表混淆", func(t *testing.T) {
+		_, err := sharding.Route(sharding.RouteBuilder().Primary("users").Strategy(sharding.NewConsistentHash(0, "2024", "202401")).Keys(1))
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Key"})
+		_, err = sharding.Route(sharding.RouteBuilder().Primary("users").Strategy(sharding.NewConsistentHash(0, "2024", "a")))
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Key"})
+		_, err = sharding.New(offlineBuilder(t).Key(sharding.Identity{}, 20250821)).GetTableName()
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Key"})
+		c := sharding.NewMemoryCache(0)
+		c.Store(context.Background(), sharding.CacheKey("test", "user_logs_42_20250821"))
+		table, err := sharding.New(offlineBuilder(t).Key(sharding.Identity{}, 42).Cache(c)).GetTableName()
+		require.NoError(t, err)
+		require.Equal(t, "user_logs_42_20250821", table)
+	})
+
+	t.Run("一致性哈希", func(t *testing.T) {
+		ch := sharding.NewConsistentHash(0, "a", "b", "c", "b")
+		require.Equal(t, []string{"a", "b", "c"}, ch.Shards())
+		var counts = make(map[string]int)
+		var before = make(map[int64]string)
+		for key := int64(0); key < 3000; key++ {
+			suffix, err := ch.Shard(key)
+			require.NoError(t, err)
+			counts[suffix]++
+			before[key] = suffix
+		}
+		for _, count := range counts {
+			require.Greater(t, count, 600)
+		}
+		// 增加节点只迁移少量 key，且只迁移到新节点
+		grown := sharding.NewConsistentHash(0, "a", "b", "c", "d")
+		var moved int
+		for key, suffix := range before {
+			after, err := grown.Shard(key)
+			require.NoError(t, err)
+			if after != suffix {
+				require.Equal(t, "d", after)
+				moved++
+			}
+		}
+		require.Less(t, moved, 1200)
+		_, err := sharding.NewConsistentHash(0).Shard(1)
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Key"})
+	})
+
+	t.Run("范围查表", func(t *testing.T) {
+		rl := sharding.NewRangeLookup(
+			sharding.KeyRange{Min: 1000, Max: 2000, Shard: "01"},
+			sharding.KeyRange{Min: 0, Max: 1000, Shard: "00"},
+		)
+		suffix, err := rl.Shard(999)
+		require.NoError(t, err)
+		require.Equal(t, "00", suffix)
+		suffix, err = rl.Shard(1000)
+		require.NoError(t, err)
+		require.Equal(t, "01", suffix)
+		_, err = rl.Shard(2000)
+		require.ErrorIs(t, err, sharding.ErrNoShard)
+		require.Equal(t, []string{"00", "01"}, rl.Shards())
+
+		_, err = sharding.NewRangeLookup(sharding.KeyRange{Min: 0, Max: 10, Shard: "00"}, sharding.KeyRange{Min: 5, Max: 20, Shard: "01"}).Shard(1)
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Key"})
+	})
+}
+
+// TestRoute 测试一组 key 映射到需要查询的分表
+func TestRoute(t *testing.T) {
+	results, err := sharding.Route(sharding.RouteBuilder().Primary("users").Strategy(sharding.Modulo{N: 4}).Keys(1, 5, 2, 9, 5))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "users_1", results[0].TableName)
+	require.Equal(t, []int64{1, 5, 9}, results[0].Keys)
+	require.Equal(t, "users_2", results[1].TableName)
+
+	results, err = sharding.Route(sharding.RouteBuilder().Primary("users").Strategy(sharding.Modulo{N: 4}))
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	require.Empty(t, results[0].Keys)
+
+	_, err = sharding.Route(sharding.RouteBuilder().Primary("users").Strategy(sharding.NewRangeLookup(sharding.KeyRange{Min: 0, Max: 10, Shard: "00"})).Keys(11))
+	require.ErrorIs(t, err, sharding.ErrNoShard)
+	_, err = sharding.Route(sharding.RouteBuilder().Primary("users"))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Strategy"})
+}
+
+// TestKeyTableName 测试按 key 分表复用缓存、建表流程
+func TestKeyTableName(t *testing.T) {
+	ctx := context.Background()
+	mysqlClient, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/test")
+	require.NoError(t, err)
+	defer mysqlClient.Close()
+	redisClient := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	defer redisClient.Close()
+	builder := func() *sharding.TableOptionsBuilder {
+		return sharding.TableBuilder().MysqlClient(mysqlClient).RedisClient(redisClient).DBName("test").Primary("users")
+	}
+
+	_, err = sharding.New(builder().Key(sharding.NewConsistentHash(0, "a-b"), 7)).GetTableName()
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Key"})
+
+	c := sharding.NewMemoryCache(0)
+	c.Store(ctx, sharding.CacheKey("test", "users_07"))
+	for _, key := range []int64{7, 71, 135} {
+		table, err := sharding.New(builder().Key(sharding.Modulo{N: 64}, key).Cache(c)).GetTableNameContext(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "users_07", table, fmt.Sprint(key))
+	}
+
+	// 组合分表：key 后缀 + 时间分表
+	c.Store(ctx, sharding.CacheKey("test", "user_logs_t42_20250821"))
+	table, err := sharding.New(offlineBuilder(t).Key(sharding.Identity{Prefix: "t"}, 42).Cache(c)).GetTableNameContext(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "user_logs_t42_20250821", table)
+	_, err = sharding.New(builder().Key(sharding.Identity{Prefix: "t"}, 42).Type(sharding.Month)).GetTableName()
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "ThisTime"})
+	_, err = sharding.New(builder().Key(sharding.Identity{Prefix: "t"}, -1)).GetTableName()
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Key"})
+}
+
+// TestCompositeParams 测试组合分表按 key 集合和时间范围展开为笛卡尔积
+func TestCompositeParams(t *testing.T) {
+	params, err := sharding.Params(sharding.ParamsBuilder().
+		Primary("orders").
+		Start(time.Date(2025, 8, 20, 0, 0, 0, 0, time.Local)).
+		End(time.Date(2025, 9, 10, 0, 0, 0, 0, time.Local)).
+		Type(sharding.Month).
+		Keys(sharding.Identity{Prefix: "t"}, 43, 42, 42))
+	require.NoError(t, err)
+	var tables = make([]string, 0)
+	for _, param := range params {
+		tables = append(tables, param.TableName)
+	}
+	require.Equal(t, []string{"orders_t42_202508", "orders_t42_202509", "orders_t43_202508", "orders_t43_202509"}, tables)
+	require.Equal(t, "t42", params[0].Shard)
+	require.Equal(t, []int64{42}, params[0].Keys)
+	require.Equal(t, time.Date(2025, 8, 20, 0, 0, 0, 0, time.Local), params[0].Start)
+	require.Equal(t, time.Date(2025, 9, 1, 0, 0, 0, 0, time.Local), params[0].End)
+	require.Equal(t, time.Date(2025, 9, 10, 0, 0, 0, 0, time.Local), params[3].End)
+
+	params, err = sharding.Params(sharding.ParamsBuilder().
+		Primary("orders").
+		Start(time.Date(2025, 8, 20, 0, 0, 0, 0, time.Local)).
+		End(time.Date(2025, 8, 21, 0, 0, 0, 0, time.Local)).
+		Type(sharding.Day).
+		Keys(sharding.Modulo{N: 2}))
+	require.NoError(t, err)
+	require.Len(t, params, 2)
+	require.Equal(t, "orders_1_20250820", params[1].TableName)
+
+	_, err = sharding.Params(sharding.ParamsBuilder().
+		Primary("orders").
+		Start(time.Date(2025, 8, 20, 0, 0, 0, 0, time.Local)).
+		End(time.Date(2025, 8, 21, 0, 0, 0, 0, time.Local)).
+		Type(sharding.Day).
+		Keys(sharding.Identity{Prefix: "t"}))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Keys"})
+}
@@ -0,0 +1,61 @@
+package tester
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLogger 测试分表日志输出到注入的 slog.Logger，带结构化字段
+func TestLogger(t *testing.T) {
+	mysqlClient, redisClient := setupMysql(t), setupRedis(t)
+	_, err := mysqlClient.Exec("CREATE TABLE `test`.`logger_logs` (`id` INT PRIMARY KEY)")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tableOption := sharding.New(sharding.TableBuilder().
+		MysqlClient(mysqlClient).
+		RedisClient(redisClient).
+		DBName("test").
+		Primary("logger_logs").
+		Logger(logger).
+		Cache(sharding.NewMemoryCache(0)).
+		ThisTime(time.Date(2025, 8, 21, 10, 0, 0, 0, time.UTC)).
+		Type(sharding.Day))
+	for i := 0; i < 2; i++ {
+		_, err = tableOption.GetTableName()
+		require.NoError(t, err)
+	}
+
+	var records = make([]map[string]any, 0)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	var created, hit bool
+	for _, record := range records {
+		require.Equal(t, "test", record["db"])
+		require.Equal(t, "logger_logs", record["primary"])
+		require.Equal(t, "logger_logs_20250821", record["table"])
+		switch record["msg"] {
+		case "sharding.GetTableName，创建新表":
+			created = true
+			require.Equal(t, "INFO", record["level"])
+			require.Contains(t, record["sql"], "CREATE TABLE IF NOT EXISTS `test`.`logger_logs_20250821`")
+		case "sharding.GetTableName，缓存命中":
+			hit = true
+			require.Equal(t, "DEBUG", record["level"])
+		case "sharding.GetTableName，分布式锁获取成功":
+			require.Contains(t, record, "lock_wait")
+		}
+	}
+	require.True(t, created)
+	require.True(t, hit)
+}
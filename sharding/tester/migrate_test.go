@@ -0,0 +1,142 @@
+package tester
+
+import (
+	"context"
+	"database/sql"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestMigrateValidation 测试迁移参数校验，不需要连接数据库
+func TestMigrateValidation(t *testing.T) {
+	mysqlClient, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/test")
+	require.NoError(t, err)
+	defer mysqlClient.Close()
+	ctx := context.Background()
+	builder := func(migrations ...*sharding.Migration) *sharding.MigrateOptionsBuilder {
+		return sharding.MigrateBuilder().MysqlClient(mysqlClient).DBName("test").Primary("user_logs").Migrations(migrations...)
+	}
+
+	_, err = sharding.Migrate(ctx, sharding.MigrateBuilder().DBName("test").Primary("user_logs"))
+	require.Error(t, err)
+
+	_, err = sharding.Migrate(ctx, builder())
+	require.Error(t, err)
+
+	_, err = sharding.Migrate(ctx, builder(&sharding.Migration{Version: 0, SQL: "ALTER TABLE `{{.Table}}` ADD COLUMN `a` INT"}))
+	require.ErrorContains(t, err, "迁移版本号必须大于0")
+
+	_, err = sharding.Migrate(ctx, builder(
+		&sharding.Migration{Version: 1, SQL: "ALTER TABLE `{{.Table}}` ADD COLUMN `a` INT"},
+		&sharding.Migration{Version: 1, SQL: "ALTER TABLE `{{.Table}}` ADD COLUMN `b` INT"},
+	))
+	require.ErrorContains(t, err, "迁移版本号重复")
+
+	_, err = sharding.Migrate(ctx, builder(&sharding.Migration{Version: 1, SQL: "ALTER TABLE `{{.Table` ADD COLUMN `a` INT"}))
+	require.ErrorContains(t, err, "sql 模板解析失败")
+}
+
+// TestMigrate 测试迁移应用到基础表和所有分表，重复执行跳过，新分表继承版本，已存在的分表不继承
+func TestMigrate(t *testing.T) {
+	mysqlClient, redisClient := setupMysql(t), setupRedis(t)
+	ctx := context.Background()
+
+	_, err := mysqlClient.Exec("CREATE TABLE `test`.`migrate_logs` (`id` BIGINT NOT NULL AUTO_INCREMENT, PRIMARY KEY (`id`)) ENGINE=InnoDB")
+	require.NoError(t, err)
+	newShard := func(day int) string {
+		tableName, err := sharding.New(sharding.TableBuilder().
+			MysqlClient(mysqlClient).
+			RedisClient(redisClient).
+			DBName("test").
+			Primary("migrate_logs").
+			ThisTime(time.Date(2025, 8, day, 10, 0, 0, 0, time.Local)).
+			Type(sharding.Day)).GetTableName()
+		require.NoError(t, err)
+		return tableName
+	}
+	newShard(20)
+	newShard(21)
+
+	builder := sharding.MigrateBuilder().
+		MysqlClient(mysqlClient).
+		DBName("test").
+		Primary("migrate_logs").
+		Concurrency(2).
+		Migrations(
+			&sharding.Migration{Version: 2, Name: "add ip", SQL: "ALTER TABLE `{{.DB}}`.`{{.Table}}` ADD COLUMN `ip` VARCHAR(64) NOT NULL DEFAULT ''"},
+			&sharding.Migration{Version: 1, Name: "add user_id", SQL: "ALTER TABLE `{{.DB}}`.`{{.Table}}` ADD COLUMN `user_id` BIGINT NOT NULL DEFAULT 0"},
+		)
+	result, err := sharding.Migrate(ctx, builder)
+	require.NoError(t, err)
+	require.Len(t, result.Applied, 3)
+	require.Equal(t, []int64{1, 2}, result.Applied["migrate_logs_20250820"])
+	require.Equal(t, 0, result.Skipped)
+
+	// 重复执行全部跳过
+	result, err = sharding.Migrate(ctx, builder)
+	require.NoError(t, err)
+	require.Empty(t, result.Applied)
+	require.Equal(t, 3, result.Skipped)
+
+	// 新分表复制最新结构并继承版本，不会重复执行
+	newShard(22)
+	result, err = sharding.Migrate(ctx, builder)
+	require.NoError(t, err)
+	require.Empty(t, result.Applied)
+	require.Equal(t, 4, result.Skipped)
+
+	// 缓存未命中但分表已存在（例如旧进程创建、未迁移），不继承版本，迁移照常执行
+	_, err = mysqlClient.Exec("CREATE TABLE `test`.`migrate_logs_20250823` (`id` BIGINT NOT NULL AUTO_INCREMENT, PRIMARY KEY (`id`)) ENGINE=InnoDB")
+	require.NoError(t, err)
+	newShard(23)
+	result, err = sharding.Migrate(ctx, builder)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, result.Applied["migrate_logs_20250823"])
+
+	reports, err := sharding.CheckDrift(ctx, mysqlClient, "test", "migrate_logs")
+	require.NoError(t, err)
+	for _, report := range reports {
+		require.False(t, report.HasDrift(), report.Table)
+	}
+}
+
+// TestMigrateSchema 测试按建表模板新建的分表写入模板已包含的迁移记录，基础表不存在时 Migrate 不重复执行
+func TestMigrateSchema(t *testing.T) {
+	mysqlClient, redisClient := setupMysql(t), setupRedis(t)
+	ctx := context.Background()
+
+	migrations := []*sharding.Migration{
+		{Version: 1, Name: "add user_id", SQL: "ALTER TABLE `{{.DB}}`.`{{.Table}}` ADD COLUMN `user_id` BIGINT NOT NULL DEFAULT 0"},
+	}
+	newShard := func(day int) string {
+		tableName, err := sharding.New(sharding.TableBuilder().
+			MysqlClient(mysqlClient).
+			RedisClient(redisClient).
+			DBName("test").
+			Primary("schema_migrate_logs").
+			ThisTime(time.Date(2025, 8, day, 10, 0, 0, 0, time.Local)).
+			Type(sharding.Day).
+			Schema("CREATE TABLE `{{.DB}}`.`{{.Table}}` (`id` BIGINT NOT NULL AUTO_INCREMENT, `user_id` BIGINT NOT NULL DEFAULT 0, PRIMARY KEY (`id`)) ENGINE=InnoDB").
+			SchemaMigrations(migrations...)).GetTableName()
+		require.NoError(t, err)
+		return tableName
+	}
+	newShard(20)
+
+	builder := sharding.MigrateBuilder().MysqlClient(mysqlClient).DBName("test").Primary("schema_migrate_logs").Migrations(migrations...)
+	result, err := sharding.Migrate(ctx, builder)
+	require.NoError(t, err)
+	require.Empty(t, result.Failed)
+	require.Empty(t, result.Applied)
+	require.Equal(t, 1, result.Skipped)
+
+	// 模板之外的新迁移照常执行
+	migrations = append(migrations, &sharding.Migration{Version: 2, Name: "add ip", SQL: "ALTER TABLE `{{.DB}}`.`{{.Table}}` ADD COLUMN `ip` VARCHAR(64) NOT NULL DEFAULT ''"})
+	builder = sharding.MigrateBuilder().MysqlClient(mysqlClient).DBName("test").Primary("schema_migrate_logs").Migrations(migrations...)
+	result, err = sharding.Migrate(ctx, builder)
+	require.NoError(t, err)
+	require.Empty(t, result.Failed)
+	require.Equal(t, []int64{2}, result.Applied["schema_migrate_logs_20250820"])
+}
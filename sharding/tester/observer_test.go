@@ -0,0 +1,83 @@
+package tester
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// splitObserver 记录 ParamsSplit 回调
+type splitObserver struct {
+	sharding.NopObserver
+	ctx     context.Context
+	buckets int
+}
+
+func (so *splitObserver) ParamsSplit(ctx context.Context, _ string, _ sharding.Type, buckets int) {
+	so.ctx, so.buckets = ctx, buckets
+}
+
+// TestMetrics 测试指标收集和 prometheus、expvar 输出
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	metrics := sharding.NewMetrics()
+	var observer sharding.Observer = metrics
+
+	observer.CacheHit(ctx, "test", "logs", "logs_20250821")
+	observer.CacheHit(ctx, "test", "logs", "logs_20250821")
+	observer.CacheMiss(ctx, "test", "logs", "logs_20250822")
+	observer.LockAcquired(ctx, "test", "logs", 30*time.Millisecond, 1)
+	observer.LockFailed(ctx, "test", "logs", 5*time.Second, 51)
+	observer.DDLExecuted(ctx, "test", "logs", "logs_20250822", 20*time.Millisecond, nil)
+	observer.DDLExecuted(ctx, "test", "logs", "logs_20250822", 20*time.Millisecond, errors.New("boom"))
+
+	require.Equal(t, 2.0, metrics.Counter("sharding_cache_hits_total", "test", "logs"))
+	require.Equal(t, 1.0, metrics.Counter("sharding_cache_misses_total", "test", "logs"))
+	require.Equal(t, 2.0, metrics.Counter("sharding_ddl_executed_total", "test", "logs"))
+	require.Equal(t, 1.0, metrics.Counter("sharding_ddl_errors_total", "test", "logs"))
+
+	_, err := sharding.ParamsContext(ctx, sharding.ParamsBuilder().
+		Primary("logs").
+		Start(time.Date(2025, 8, 19, 17, 0, 0, 0, time.Local)).
+		End(time.Date(2025, 8, 22, 0, 0, 0, 0, time.Local)).
+		Type(sharding.Day).
+		Observer(metrics))
+	require.NoError(t, err)
+	require.Equal(t, 1.0, metrics.Counter("sharding_params_splits_total", "", "logs"))
+
+	// ParamsSplit 回调带上 ParamsContext 传入的 ctx
+	type ctxKey struct{}
+	splits := &splitObserver{}
+	_, err = sharding.ParamsContext(context.WithValue(ctx, ctxKey{}, "trace"), sharding.ParamsBuilder().
+		Primary("logs").
+		Start(time.Date(2025, 8, 19, 17, 0, 0, 0, time.Local)).
+		End(time.Date(2025, 8, 22, 0, 0, 0, 0, time.Local)).
+		Type(sharding.Day).
+		Observer(splits))
+	require.NoError(t, err)
+	require.Equal(t, "trace", splits.ctx.Value(ctxKey{}))
+	require.Equal(t, 3, splits.buckets)
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	require.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+	require.Contains(t, body, "# TYPE sharding_cache_hits_total counter\n")
+	require.Contains(t, body, `sharding_cache_hits_total{db="test",primary="logs"} 2`)
+	require.Contains(t, body, "# TYPE sharding_lock_wait_seconds histogram\n")
+	require.Contains(t, body, `sharding_lock_wait_seconds_bucket{db="test",primary="logs",le="0.05"} 1`)
+	require.Contains(t, body, `sharding_lock_wait_seconds_bucket{db="test",primary="logs",le="+Inf"} 2`)
+	require.Contains(t, body, `sharding_lock_attempts_count{db="test",primary="logs"} 2`)
+	require.Contains(t, body, `sharding_params_buckets_bucket{primary="logs",le="2"} 0`)
+	require.Contains(t, body, `sharding_params_buckets_bucket{primary="logs",le="4"} 1`)
+
+	var values map[string]any
+	require.NoError(t, json.Unmarshal([]byte(metrics.String()), &values))
+	require.Equal(t, 2.0, values[`sharding_cache_hits_total{db="test",primary="logs"}`])
+	require.Contains(t, values, `sharding_ddl_duration_seconds{db="test",primary="logs"}`)
+}
@@ -0,0 +1,86 @@
+package tester
+
+import (
+	"context"
+	"database/sql"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestPlacement 测试按时间、key 后缀把分表放到不同的数据库
+func TestPlacement(t *testing.T) {
+	ctx := context.Background()
+	open := func(dsn string) *sql.DB {
+		client, err := sql.Open("mysql", dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+	client2024, client2025 := open("root:root@tcp(10.0.0.1:3306)/logs"), open("root:root@tcp(10.0.0.2:3306)/logs")
+	placement := sharding.NewPlacement().
+		Target("cluster_2024", client2024, "logs_2024").
+		Target("cluster_2025", client2025, "logs_2025").
+		Target("tenant_a", client2025, "tenant_a").
+		Shards("tenant_a", "t1", "t2").
+		Range(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), "cluster_2024").
+		Range(time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local), "cluster_2025")
+
+	t.Run("规则匹配", func(t *testing.T) {
+		target, err := placement.Resolve("", time.Date(2024, 12, 1, 0, 0, 0, 0, time.Local))
+		require.NoError(t, err)
+		require.Equal(t, "cluster_2024", target.Name)
+		require.Same(t, client2024, target.Client)
+		target, err = placement.Resolve("t2", time.Date(2024, 12, 1, 0, 0, 0, 0, time.Local))
+		require.NoError(t, err)
+		require.Equal(t, "tenant_a", target.Name)
+		_, err = placement.Resolve("", time.Date(2023, 12, 1, 0, 0, 0, 0, time.Local))
+		require.ErrorIs(t, err, sharding.ErrNoPlacement)
+		_, err = sharding.NewPlacement().Default("missing").Resolve("", time.Now())
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Placement"})
+	})
+
+	t.Run("Params 带连接", func(t *testing.T) {
+		params, err := sharding.Params(sharding.ParamsBuilder().
+			Primary("logs").
+			Start(time.Date(2024, 12, 15, 0, 0, 0, 0, time.Local)).
+			End(time.Date(2025, 2, 1, 0, 0, 0, 0, time.Local)).
+			Type(sharding.Month).
+			Placement(placement))
+		require.NoError(t, err)
+		require.Len(t, params, 2)
+		require.Equal(t, "logs_202412", params[0].TableName)
+		require.Equal(t, "cluster_2024", params[0].Target.Name)
+		require.Equal(t, "logs_202501", params[1].TableName)
+		require.Equal(t, "logs_2025", params[1].Target.DB)
+	})
+
+	t.Run("GetTableName 使用目标库", func(t *testing.T) {
+		redisClient := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+		defer redisClient.Close()
+		c := sharding.NewMemoryCache(0)
+		c.Store(ctx, sharding.CacheKey("logs_2025", "logs_20250821"))
+		tableOption := sharding.New(sharding.TableBuilder().
+			RedisClient(redisClient).
+			Primary("logs").
+			ThisTime(time.Date(2025, 8, 21, 10, 0, 0, 0, time.Local)).
+			Type(sharding.Day).
+			Placement(placement).
+			Cache(c))
+		table, err := tableOption.GetTableNameContext(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "logs_20250821", table)
+		require.Equal(t, "cluster_2025", tableOption.Target().Name)
+		require.Same(t, client2025, tableOption.Target().Client)
+
+		_, err = sharding.New(sharding.TableBuilder().
+			RedisClient(redisClient).
+			Primary("logs").
+			ThisTime(time.Date(2023, 8, 21, 10, 0, 0, 0, time.Local)).
+			Type(sharding.Day).
+			Placement(placement)).GetTableName()
+		require.ErrorIs(t, err, sharding.ErrNoPlacement)
+	})
+}
@@ -0,0 +1,95 @@
+package tester
+
+import (
+	"context"
+	"database/sql"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPlan 测试执行计划输出和删表计划
+func TestPlan(t *testing.T) {
+	mysqlClient, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/test")
+	require.NoError(t, err)
+	defer mysqlClient.Close()
+	registry := sharding.NewRegistry(mysqlClient, "test")
+
+	_, err = registry.PlanDrop("user_logs", "user_logs")
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "table"})
+
+	plan, err := registry.PlanDrop("user_logs", "user_logs_20250820")
+	require.NoError(t, err)
+	require.Len(t, plan.Statements, 1)
+	require.Equal(t, "user_logs_20250820", plan.Statements[0].Table)
+	require.Equal(t, "DROP TABLE IF EXISTS `test`.`user_logs_20250820`", plan.Statements[0].SQL)
+
+	other, err := registry.PlanDrop("user_logs", "user_logs_20250821")
+	require.NoError(t, err)
+	plan.Merge(other, nil)
+	require.Len(t, plan.Statements, 2)
+	require.True(t, new(sharding.Plan).Empty())
+	require.False(t, plan.Empty())
+
+	var sb strings.Builder
+	n, err := plan.WriteTo(&sb)
+	require.NoError(t, err)
+	require.Equal(t, int64(sb.Len()), n)
+	require.Equal(t, "-- sharding plan，共 2 条语句\n"+
+		"\n-- user_logs_20250820：删除 user_logs 的分表\nDROP TABLE IF EXISTS `test`.`user_logs_20250820`;\n"+
+		"\n-- user_logs_20250821：删除 user_logs 的分表\nDROP TABLE IF EXISTS `test`.`user_logs_20250821`;\n", sb.String())
+	require.Equal(t, sb.String(), plan.String())
+}
+
+// TestPlanDryRun 测试建表、迁移只计算语句不执行
+func TestPlanDryRun(t *testing.T) {
+	mysqlClient, redisClient := setupMysql(t), setupRedis(t)
+	ctx := context.Background()
+
+	_, err := mysqlClient.Exec("CREATE TABLE `test`.`plan_logs` (`id` BIGINT NOT NULL AUTO_INCREMENT, PRIMARY KEY (`id`)) ENGINE=InnoDB")
+	require.NoError(t, err)
+	tableOption := sharding.New(sharding.TableBuilder().
+		MysqlClient(mysqlClient).
+		RedisClient(redisClient).
+		DBName("test").
+		Primary("plan_logs").
+		ThisTime(time.Date(2025, 8, 20, 10, 0, 0, 0, time.Local)).
+		Type(sharding.Day))
+
+	plan, err := tableOption.Plan(ctx)
+	require.NoError(t, err)
+	require.Len(t, plan.Statements, 1)
+	require.Equal(t, "plan_logs_20250820", plan.Statements[0].Table)
+	require.Contains(t, plan.Statements[0].SQL, "CREATE TABLE IF NOT EXISTS `test`.`plan_logs_20250820`")
+	shards, err := sharding.ListShards(ctx, mysqlClient, "test", "plan_logs")
+	require.NoError(t, err)
+	require.Empty(t, shards)
+
+	_, err = tableOption.GetTableName()
+	require.NoError(t, err)
+	plan, err = tableOption.Plan(ctx)
+	require.NoError(t, err)
+	require.True(t, plan.Empty())
+
+	result, err := sharding.Migrate(ctx, sharding.MigrateBuilder().
+		MysqlClient(mysqlClient).
+		DBName("test").
+		Primary("plan_logs").
+		DryRun().
+		Migrations(&sharding.Migration{Version: 1, Name: "add ip", SQL: "ALTER TABLE `{{.DB}}`.`{{.Table}}` ADD COLUMN `ip` VARCHAR(64) NOT NULL DEFAULT 0"}))
+	require.NoError(t, err)
+	require.Empty(t, result.Applied)
+	var tables = make([]string, 0)
+	for _, statement := range result.Plan.Statements {
+		tables = append(tables, statement.Table)
+	}
+	require.Equal(t, []string{sharding.MigrationTable, "plan_logs", "plan_logs_20250820"}, tables)
+	require.Equal(t, "ALTER TABLE `test`.`plan_logs_20250820` ADD COLUMN `ip` VARCHAR(64) NOT NULL DEFAULT 0", result.Plan.Statements[2].SQL)
+
+	// 未执行：基础表没有新列
+	var count int
+	require.NoError(t, mysqlClient.QueryRow("SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = 'test' AND TABLE_NAME = 'plan_logs' AND COLUMN_NAME = 'ip'").Scan(&count))
+	require.Zero(t, count)
+}
@@ -0,0 +1,91 @@
+package tester
+
+import (
+	"context"
+	"database/sql"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestRegistry 测试分表登记表记录创建、归档、删除，重复登记不改变已归档的状态，已删除的分表重新建表后改回使用中
+func TestRegistry(t *testing.T) {
+	mysqlClient, redisClient := setupMysql(t), setupRedis(t)
+	ctx := context.Background()
+	registry := sharding.NewRegistry(mysqlClient, "test")
+
+	_, err := mysqlClient.Exec("CREATE TABLE `test`.`registry_logs` (`id` BIGINT NOT NULL AUTO_INCREMENT, PRIMARY KEY (`id`)) ENGINE=InnoDB")
+	require.NoError(t, err)
+	for _, month := range []time.Month{time.July, time.August, time.September} {
+		_, err = sharding.New(sharding.TableBuilder().
+			MysqlClient(mysqlClient).
+			RedisClient(redisClient).
+			DBName("test").
+			Primary("registry_logs").
+			Registry(registry).
+			ThisTime(time.Date(2025, month, 10, 0, 0, 0, 0, time.Local)).
+			Type(sharding.Month)).GetTableName()
+		require.NoError(t, err)
+	}
+
+	entries, err := registry.List(ctx, "registry_logs")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, "registry_logs_202507", entries[0].Table)
+	require.Equal(t, sharding.StatusActive, entries[0].Status)
+	require.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.Local).Unix(), entries[0].BucketStart.Unix())
+	require.Equal(t, time.Date(2025, 8, 1, 0, 0, 0, 0, time.Local).Unix(), entries[0].BucketEnd.Unix())
+
+	require.NoError(t, registry.Archive(ctx, "registry_logs", "registry_logs_202507"))
+	require.NoError(t, registry.Drop(ctx, "registry_logs", "registry_logs_202508"))
+	require.Error(t, registry.Drop(ctx, "registry_logs", "registry_logs"), "不允许删除基础表")
+
+	// 重新获取已存在的分表（缓存未命中）或重复登记，不会把已归档改回使用中
+	_, err = sharding.New(sharding.TableBuilder().
+		MysqlClient(mysqlClient).
+		RedisClient(redisClient).
+		DBName("test").
+		Primary("registry_logs").
+		Registry(registry).
+		Cache(sharding.NewMemoryCache(0)).
+		ThisTime(time.Date(2025, time.July, 20, 0, 0, 0, 0, time.Local)).
+		Type(sharding.Month)).GetTableName()
+	require.NoError(t, err)
+	require.NoError(t, registry.Register(ctx, "registry_logs", "registry_logs_202507",
+		time.Date(2025, 7, 1, 0, 0, 0, 0, time.Local), time.Date(2025, 8, 1, 0, 0, 0, 0, time.Local)))
+	entry, err := registry.Get(ctx, "registry_logs_202507")
+	require.NoError(t, err)
+	require.Equal(t, sharding.StatusArchived, entry.Status)
+
+	entry, err = registry.Get(ctx, "registry_logs_202508")
+	require.NoError(t, err)
+	require.Equal(t, sharding.StatusDropped, entry.Status)
+	var count int
+	err = mysqlClient.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'test' AND table_name = 'registry_logs_202508'").Scan(&count)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	active, err := registry.List(ctx, "registry_logs", sharding.StatusActive)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	require.Equal(t, "registry_logs_202509", active[0].Table)
+
+	// 已删除的分表有迟到的写入时重新建表，登记状态改回使用中
+	_, err = sharding.New(sharding.TableBuilder().
+		MysqlClient(mysqlClient).
+		RedisClient(redisClient).
+		DBName("test").
+		Primary("registry_logs").
+		Registry(registry).
+		Cache(sharding.NewMemoryCache(0)).
+		ThisTime(time.Date(2025, time.August, 20, 0, 0, 0, 0, time.Local)).
+		Type(sharding.Month)).GetTableName()
+	require.NoError(t, err)
+	entry, err = registry.Get(ctx, "registry_logs_202508")
+	require.NoError(t, err)
+	require.Equal(t, sharding.StatusActive, entry.Status)
+
+	_, err = registry.Get(ctx, "registry_logs_202601")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
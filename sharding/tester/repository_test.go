@@ -0,0 +1,121 @@
+package tester
+
+import (
+	"context"
+	"database/sql"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type repoLog struct {
+	ID        int64     `db:"id,pk,auto"`
+	UserID    int64     `db:"user_id"`
+	Action    string    `db:"action"`
+	CreatedAt time.Time `db:"created_at" shard:"time"`
+	Ignored   string
+}
+
+type repoBase struct {
+	ID        int64     `db:"id,pk"`
+	CreatedAt time.Time `db:"created_at" shard:"time"`
+}
+
+type repoOrder struct {
+	repoBase
+	Amount int64 `db:"amount"`
+}
+
+// TestRepositoryValidation 测试结构体标签解析和参数校验
+func TestRepositoryValidation(t *testing.T) {
+	table := offlineBuilder(t)
+
+	_, err := sharding.NewRepository[repoLog](sharding.RepositoryBuilder().Table(table))
+	require.NoError(t, err)
+	// 嵌入结构体展开
+	_, err = sharding.NewRepository[repoOrder](sharding.RepositoryBuilder().Table(table))
+	require.NoError(t, err)
+
+	_, err = sharding.NewRepository[repoLog](sharding.RepositoryBuilder())
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Table"})
+	_, err = sharding.NewRepository[int](sharding.RepositoryBuilder().Table(table))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Repository"})
+	_, err = sharding.NewRepository[struct {
+		ID int64 `db:"id"`
+	}](sharding.RepositoryBuilder().Table(table))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Repository"})
+	_, err = sharding.NewRepository[struct {
+		At string `db:"at" shard:"time"`
+	}](sharding.RepositoryBuilder().Table(table))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Repository"})
+	_, err = sharding.NewRepository[struct {
+		At time.Time `db:"at,unique" shard:"time"`
+	}](sharding.RepositoryBuilder().Table(table))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Repository"})
+	client, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/test")
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = sharding.NewRepository[repoLog](sharding.RepositoryBuilder().
+		Table(offlineBuilder(t).Placement(sharding.NewPlacement().Target("logs", client, "test").Default("logs"))).
+		Cluster(sharding.NewCluster(client)))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Cluster"})
+	_, err = sharding.NewRepository[repoLog](sharding.RepositoryBuilder().Table(table.Type(0)))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Type"})
+
+	repo, err := sharding.NewRepository[repoLog](sharding.RepositoryBuilder().Table(offlineBuilder(t)))
+	require.NoError(t, err)
+	err = repo.Insert(context.Background(), repoLog{UserID: 1})
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "ThisTime"})
+}
+
+// TestRepository 测试按结构体写入、跨分表查询和按主键查询
+func TestRepository(t *testing.T) {
+	mysqlClient := setupMysql(t)
+	redisClient := setupRedis(t)
+	ctx := context.Background()
+
+	_, err := mysqlClient.Exec("CREATE TABLE `test`.`repo_logs` (" +
+		"`id` BIGINT NOT NULL AUTO_INCREMENT, `user_id` BIGINT NOT NULL, `action` VARCHAR(32) NOT NULL, " +
+		"`created_at` DATETIME NOT NULL, PRIMARY KEY (`id`))")
+	require.NoError(t, err)
+	repo, err := sharding.NewRepository[repoLog](sharding.RepositoryBuilder().
+		Table(sharding.TableBuilder().
+			MysqlClient(mysqlClient).
+			RedisClient(redisClient).
+			DBName("test").
+			Primary("repo_logs").
+			Type(sharding.Day).
+			Cache(sharding.NewMemoryCache(0))).
+		BatchSize(2).
+		FanOut(sharding.FanOutBuilder().Concurrency(2)))
+	require.NoError(t, err)
+
+	day := time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)
+	err = repo.Insert(ctx,
+		repoLog{UserID: 1, Action: "login", CreatedAt: day},
+		repoLog{UserID: 2, Action: "login", CreatedAt: day.Add(time.Hour)},
+		repoLog{UserID: 1, Action: "pay", CreatedAt: day.Add(2 * time.Hour)},
+		repoLog{UserID: 1, Action: "logout", CreatedAt: day.AddDate(0, 0, 1)},
+	)
+	require.NoError(t, err)
+	shards, err := sharding.ListShards(ctx, mysqlClient, "test", "repo_logs")
+	require.NoError(t, err)
+	require.Len(t, shards, 2)
+
+	// 第三天的分表不存在，视为没有数据
+	logs, err := repo.FindRange(ctx, day.Add(-time.Hour), day.AddDate(0, 0, 3), "`user_id` = ?", 1)
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	require.Equal(t, "login", logs[0].Action)
+	require.Equal(t, "logout", logs[2].Action)
+	require.True(t, day.Equal(logs[0].CreatedAt))
+
+	log, err := repo.Get(ctx, logs[2].ID, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, "logout", log.Action)
+	_, err = repo.Get(ctx, logs[2].ID, day)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = repo.Get(ctx, 1, day.AddDate(0, 0, 5))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,57 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestRetain 测试删除超出保留期的分表
+func TestRetain(t *testing.T) {
+	mysqlClient := setupMysql(t)
+	ctx := context.Background()
+
+	for _, table := range []string{"retain_logs", "retain_logs_20250819", "retain_logs_20250820", "retain_logs_20250821"} {
+		_, err := mysqlClient.Exec("CREATE TABLE `test`.`" + table + "` (`id` INT PRIMARY KEY)")
+		require.NoError(t, err)
+	}
+	builder := func() *sharding.RetainOptionsBuilder {
+		return sharding.RetainBuilder().
+			MysqlClient(mysqlClient).
+			DBName("test").
+			Primary("retain_logs").
+			Before(time.Date(2025, 8, 21, 0, 0, 0, 0, time.Local))
+	}
+
+	result, err := sharding.Retain(ctx, builder().DryRun())
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Kept)
+	require.Empty(t, result.Dropped)
+	require.Len(t, result.Plan.Statements, 2)
+	require.Equal(t, "DROP TABLE IF EXISTS `test`.`retain_logs_20250819`", result.Plan.Statements[0].SQL)
+
+	// 删表后失效 builder 设置的缓存，而不只是默认缓存；登记表在其他库时分表仍在 DBName 中删除
+	_, err = mysqlClient.Exec("CREATE DATABASE IF NOT EXISTS `retain_registry`")
+	require.NoError(t, err)
+	registry := sharding.NewRegistry(mysqlClient, "retain_registry")
+	c := sharding.NewMemoryCache(0)
+	c.Store(ctx, sharding.CacheKey("test", "retain_logs_20250819"))
+	c.Store(ctx, sharding.CacheKey("test", "retain_logs_20250820"))
+	result, err = sharding.Retain(ctx, builder().Registry(registry).Cache(c))
+	require.NoError(t, err)
+	require.Equal(t, []string{"retain_logs_20250819", "retain_logs_20250820"}, result.Dropped)
+	require.False(t, c.Exists(ctx, sharding.CacheKey("test", "retain_logs_20250819")))
+	require.False(t, c.Exists(ctx, sharding.CacheKey("test", "retain_logs_20250820")))
+	entry, err := registry.Get(ctx, "retain_logs_20250819")
+	require.NoError(t, err)
+	require.Equal(t, sharding.StatusDropped, entry.Status)
+	shards, err := sharding.ListShards(ctx, mysqlClient, "test", "retain_logs")
+	require.NoError(t, err)
+	require.Len(t, shards, 1)
+	require.Equal(t, "retain_logs_20250821", shards[0].Table)
+
+	_, err = sharding.Retain(ctx, sharding.RetainBuilder().MysqlClient(mysqlClient).DBName("test").Primary("retain_logs"))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Before"})
+}
@@ -0,0 +1,108 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// TestSchedule 测试分表粒度调整前后 New、Params 选择对应的分表类型
+func TestSchedule(t *testing.T) {
+	ctx := context.Background()
+	cutover := time.Date(2025, 9, 1, 0, 0, 0, 0, time.Local)
+	schedule := []sharding.Cutover{{Type: sharding.Month}, {From: cutover, Type: sharding.Day}}
+	names := func(params []*sharding.ParamsResult) []string {
+		var tables = make([]string, len(params))
+		for i, param := range params {
+			tables[i] = param.TableName
+		}
+		return tables
+	}
+
+	t.Run("跨调整时间查询", func(t *testing.T) {
+		params, err := sharding.Params(sharding.ParamsBuilder().
+			Primary("logs").
+			Start(time.Date(2025, 7, 15, 0, 0, 0, 0, time.Local)).
+			End(time.Date(2025, 9, 3, 0, 0, 0, 0, time.Local)).
+			Schedule(schedule...))
+		require.NoError(t, err)
+		require.Equal(t, []string{"logs_202507", "logs_202508", "logs_20250901", "logs_20250902"}, names(params))
+		require.Equal(t, sharding.Month, params[1].Type)
+		require.Equal(t, cutover, params[1].End)
+		require.Equal(t, sharding.Day, params[2].Type)
+	})
+
+	t.Run("调整时间不在分表边界并启用新基础表", func(t *testing.T) {
+		midMonth := []sharding.Cutover{{Type: sharding.Month}, {From: time.Date(2025, 9, 15, 0, 0, 0, 0, time.Local), Type: sharding.Day, Primary: "logs_v2"}}
+		params, err := sharding.Params(sharding.ParamsBuilder().
+			Primary("logs").
+			Start(time.Date(2025, 9, 10, 0, 0, 0, 0, time.Local)).
+			End(time.Date(2025, 9, 17, 12, 0, 0, 0, time.Local)).
+			Schedule(midMonth...))
+		require.NoError(t, err)
+		require.Equal(t, []string{"logs_202509", "logs_v2_20250915", "logs_v2_20250916", "logs_v2_20250917"}, names(params))
+		require.Equal(t, time.Date(2025, 9, 15, 0, 0, 0, 0, time.Local), params[0].End)
+
+		params, err = sharding.Params(sharding.ParamsBuilder().
+			Primary("logs").
+			Start(time.Date(2025, 9, 14, 12, 0, 0, 0, time.Local)).
+			End(time.Date(2025, 9, 15, 12, 0, 0, 0, time.Local)).
+			Keys(sharding.Identity{Prefix: "t"}, 42).
+			Schedule(midMonth...))
+		require.NoError(t, err)
+		require.Equal(t, []string{"logs_t42_202509", "logs_v2_t42_20250915"}, names(params))
+	})
+
+	t.Run("New 按当前时间选择分表类型", func(t *testing.T) {
+		c := sharding.NewMemoryCache(0)
+		c.Store(ctx, sharding.CacheKey("test", "user_logs_202508"))
+		c.Store(ctx, sharding.CacheKey("test", "user_logs_20250920"))
+		table, err := sharding.New(offlineBuilder(t).
+			ThisTime(time.Date(2025, 8, 31, 23, 0, 0, 0, time.Local)).
+			Schedule(schedule...).
+			Cache(c)).GetTableName()
+		require.NoError(t, err)
+		require.Equal(t, "user_logs_202508", table)
+		table, err = sharding.New(offlineBuilder(t).
+			ThisTime(time.Date(2025, 9, 20, 8, 0, 0, 0, time.Local)).
+			Schedule(schedule...).
+			Cache(c)).GetTableName()
+		require.NoError(t, err)
+		require.Equal(t, "user_logs_20250920", table)
+	})
+
+	t.Run("Repository 按数据时间选择分表", func(t *testing.T) {
+		// 调整时间不在分表边界，调整后变粗：9 月 15 日起按月
+		coarser := []sharding.Cutover{{Type: sharding.Day}, {From: time.Date(2025, 9, 15, 0, 0, 0, 0, time.Local), Type: sharding.Month}}
+		c := sharding.NewMemoryCache(0)
+		c.Store(ctx, sharding.CacheKey("test", "user_logs_20250901"))
+		c.Store(ctx, sharding.CacheKey("test", "user_logs_202509"))
+		repo, err := sharding.NewRepository[repoLog](sharding.RepositoryBuilder().Table(offlineBuilder(t).Schedule(coarser...).Cache(c)))
+		require.NoError(t, err)
+		// 离线环境写入失败，错误中带有写入的分表名
+		err = repo.Insert(ctx, repoLog{CreatedAt: time.Date(2025, 9, 20, 8, 0, 0, 0, time.Local)})
+		require.ErrorContains(t, err, "user_logs_202509：")
+		err = repo.Insert(ctx, repoLog{CreatedAt: time.Date(2025, 9, 1, 8, 0, 0, 0, time.Local)})
+		require.ErrorContains(t, err, "user_logs_20250901：")
+	})
+
+	t.Run("参数校验", func(t *testing.T) {
+		_, err := sharding.Params(sharding.ParamsBuilder().
+			Primary("logs").
+			Start(cutover).
+			End(cutover).
+			Schedule(sharding.Cutover{From: cutover, Type: sharding.Month}, sharding.Cutover{From: cutover, Type: sharding.Day}))
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Schedule"})
+		_, err = sharding.New(offlineBuilder(t).Schedule(sharding.Cutover{Type: sharding.Type(7)})).GetTableName()
+		require.ErrorIs(t, err, sharding.ErrUnknownType)
+		_, err = sharding.FindGapsParams(ctx, nil, "test", sharding.ParamsBuilder().
+			Primary("logs").
+			Start(cutover).
+			End(cutover).
+			Keys(sharding.Identity{Prefix: "t"}, 42).
+			Schedule(schedule...))
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Params"})
+	})
+}
@@ -0,0 +1,114 @@
+package tester
+
+import (
+	"database/sql"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// offlineBuilder 不连接 mysql、redis 的构建器，只用于参数校验
+func offlineBuilder(t *testing.T) *sharding.TableOptionsBuilder {
+	t.Helper()
+	mysqlClient, err := sql.Open("mysql", "root:root@tcp(127.0.0.1:3306)/test")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, mysqlClient.Close()) })
+	redisClient := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	t.Cleanup(func() { require.NoError(t, redisClient.Close()) })
+	return sharding.TableBuilder().
+		MysqlClient(mysqlClient).
+		RedisClient(redisClient).
+		DBName("test").
+		Primary("user_logs").
+		ThisTime(time.Date(2025, 8, 21, 15, 30, 0, 0, time.UTC)).
+		Type(sharding.Day)
+}
+
+// TestSchemaValidation 测试建表模板在 New() 时校验
+func TestSchemaValidation(t *testing.T) {
+	t.Run("模板语法错误", func(t *testing.T) {
+		_, err := sharding.New(offlineBuilder(t).Schema("CREATE TABLE `{{.Table` (`id` INT)")).GetTableName()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "建表模板解析失败")
+	})
+
+	t.Run("模板参数不存在", func(t *testing.T) {
+		_, err := sharding.New(offlineBuilder(t).Schema("CREATE TABLE `{{.Name}}` (`id` INT)")).GetTableName()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "建表模板渲染失败")
+	})
+
+	t.Run("不是建表语句", func(t *testing.T) {
+		_, err := sharding.New(offlineBuilder(t).Schema("ALTER TABLE `{{.Table}}` ADD COLUMN `x` INT")).GetTableName()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "必须是 CREATE TABLE 语句")
+	})
+
+	t.Run("表名不是分表名", func(t *testing.T) {
+		for _, ddl := range []string{
+			"CREATE TABLE `{{.DB}}`.`{{.Primary}}` (`id` INT)",
+			"CREATE TABLE IF NOT EXISTS `user_logs_20250101` (`id` INT)",
+			"CREATE TABLE `other`.`{{.Table}}` (`id` INT)",
+		} {
+			_, err := sharding.New(offlineBuilder(t).Schema(ddl)).GetTableName()
+			require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Schema"}, ddl)
+			require.ErrorContains(t, err, "表名需要是")
+		}
+		for _, ddl := range []string{
+			"CREATE TABLE `{{.DB}}`.`{{.Table}}` (`id` INT)",
+			"create table if not exists {{.DB}} . {{.Table}}(`id` INT)",
+			"CREATE TABLE `{{.Table}}` (`id` INT)",
+		} {
+			builder := offlineBuilder(t).Schema(ddl)
+			// 模板在 builder 中解析一次，多次 New 复用
+			require.NotNil(t, sharding.New(builder).Target(), ddl)
+			require.NotNil(t, sharding.New(builder).Target(), ddl)
+		}
+	})
+
+	t.Run("模板迁移版本无效", func(t *testing.T) {
+		_, err := sharding.New(offlineBuilder(t).Schema("CREATE TABLE `{{.Table}}` (`id` INT)").
+			SchemaMigrations(&sharding.Migration{Version: 0, SQL: "ALTER TABLE `{{.Table}}` ADD COLUMN `a` INT"})).GetTableName()
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Migrations"})
+	})
+
+	t.Run("模板文件不存在", func(t *testing.T) {
+		fsys := fstest.MapFS{}
+		_, err := sharding.New(offlineBuilder(t).SchemaFS(fsys, "schema/user_logs.sql")).GetTableName()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "建表模板读取失败")
+	})
+
+	t.Run("模板文件语法错误", func(t *testing.T) {
+		fsys := fstest.MapFS{"schema/user_logs.sql": {Data: []byte("CREATE TABLE {{if}}")}}
+		_, err := sharding.New(offlineBuilder(t).SchemaFS(fsys, "schema/user_logs.sql")).GetTableName()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "建表模板解析失败")
+	})
+}
+
+// TestTypeBucket 测试分表时间范围计算
+func TestTypeBucket(t *testing.T) {
+	var tm = time.Date(2024, 2, 29, 15, 45, 30, 0, time.UTC)
+	testCases := []struct {
+		t     sharding.Type
+		start time.Time
+		end   time.Time
+	}{
+		{sharding.Hour, time.Date(2024, 2, 29, 15, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 16, 0, 0, 0, time.UTC)},
+		{sharding.Day, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{sharding.Month, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{sharding.Year, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range testCases {
+		start, end := tc.t.Bucket(tm)
+		require.Equal(t, tc.start, start)
+		require.Equal(t, tc.end, end)
+	}
+	start, end := sharding.Type(99).Bucket(tm)
+	require.True(t, start.IsZero())
+	require.True(t, end.IsZero())
+}
@@ -0,0 +1,53 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// TestStats 测试分表容量统计、增长率和阈值检查
+func TestStats(t *testing.T) {
+	mysqlClient := setupMysql(t)
+	ctx := context.Background()
+
+	for table, rows := range map[string]int{"stats_logs_20250820": 10, "stats_logs_20250821": 40} {
+		_, err := mysqlClient.Exec("CREATE TABLE `test`.`" + table + "` (`id` INT PRIMARY KEY, `name` VARCHAR(32), KEY `idx_name` (`name`))")
+		require.NoError(t, err)
+		var values = make([]string, 0, rows)
+		for i := 1; i <= rows; i++ {
+			values = append(values, fmt.Sprintf("(%d, 'name_%d')", i, i))
+		}
+		_, err = mysqlClient.Exec("INSERT INTO `test`.`" + table + "` VALUES " + strings.Join(values, ","))
+		require.NoError(t, err)
+		_, err = mysqlClient.Exec("ANALYZE TABLE `test`.`" + table + "`")
+		require.NoError(t, err)
+	}
+	// ANALYZE TABLE 会刷新 information_schema 缓存的统计信息
+	report, err := sharding.Stats(ctx, sharding.StatsBuilder().
+		MysqlClient(mysqlClient).
+		DBName("test").
+		Primary("stats_logs").
+		MaxRows(20).
+		MaxGrowth(1))
+	require.NoError(t, err)
+	require.Len(t, report.Shards, 2)
+	require.Equal(t, "stats_logs_20250820", report.Shards[0].Table)
+	require.Zero(t, report.Shards[0].RowsGrowth)
+	require.Positive(t, report.Shards[1].DataLength)
+	require.Positive(t, report.Shards[1].IndexLength)
+
+	var metrics = make([]string, 0)
+	for _, violation := range report.Violations {
+		require.Equal(t, "stats_logs_20250821", violation.Table)
+		metrics = append(metrics, violation.Metric)
+	}
+	require.Contains(t, metrics, sharding.MetricRows)
+	require.Contains(t, metrics, sharding.MetricRowsGrowth)
+
+	_, err = sharding.Stats(ctx, sharding.StatsBuilder().MysqlClient(mysqlClient).DBName("test"))
+	require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Primary"})
+}
@@ -265,6 +265,7 @@ func TestGetTableNameEdgeCases(t *testing.T) {
 		_, err := tableOption.GetTableName()
 		// 基础表不存在应该报错
 		require.Error(t, err)
+		require.ErrorIs(t, err, sharding.ErrBaseTableMissing)
 	})
 
 	t.Run("数据库不存在", func(t *testing.T) {
@@ -0,0 +1,168 @@
+package tester
+
+import (
+	"context"
+	"errors"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordedSpan 记录的 span
+type recordedSpan struct {
+	noop.Span
+	recorder *spanRecorder
+	name     string
+	parent   *recordedSpan
+	attrs    map[attribute.Key]attribute.Value
+	status   codes.Code
+}
+
+func (rs *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	rs.recorder.mu.Lock()
+	defer rs.recorder.mu.Unlock()
+	for _, attr := range kv {
+		rs.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (rs *recordedSpan) SetStatus(code codes.Code, _ string) {
+	rs.recorder.mu.Lock()
+	defer rs.recorder.mu.Unlock()
+	rs.status = code
+}
+
+func (rs *recordedSpan) End(...trace.SpanEndOption) {
+	rs.recorder.mu.Lock()
+	defer rs.recorder.mu.Unlock()
+	rs.recorder.ended = append(rs.recorder.ended, rs)
+}
+
+// spanRecorder 记录 span 的 TracerProvider，只用于测试
+type spanRecorder struct {
+	embedded.TracerProvider
+	mu    sync.Mutex
+	ended []*recordedSpan
+}
+
+func (sr *spanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return &recordedTracer{recorder: sr}
+}
+
+type recordedTracer struct {
+	embedded.Tracer
+	recorder *spanRecorder
+}
+
+func (rt *recordedTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	var span = &recordedSpan{recorder: rt.recorder, name: name, attrs: make(map[attribute.Key]attribute.Value)}
+	span.parent, _ = trace.SpanFromContext(ctx).(*recordedSpan)
+	config := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(config.Attributes()...)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (sr *spanRecorder) byName(name string) []*recordedSpan {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	var spans = make([]*recordedSpan, 0)
+	for _, span := range sr.ended {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// TestTracing 测试获取分表名和跨分表查询的 span
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+	recorder := &spanRecorder{}
+
+	t.Run("缓存命中", func(t *testing.T) {
+		c := sharding.NewMemoryCache(0)
+		c.Store(ctx, sharding.CacheKey("test", "user_logs_20250821"))
+		table, err := sharding.New(offlineBuilder(t).Cache(c).TracerProvider(recorder)).GetTableNameContext(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "user_logs_20250821", table)
+
+		spans := recorder.byName("sharding.GetTableName")
+		require.Len(t, spans, 1)
+		require.Equal(t, "test", spans[0].attrs["sharding.db"].AsString())
+		require.Equal(t, "user_logs", spans[0].attrs["sharding.primary"].AsString())
+		require.Equal(t, "user_logs_20250821", spans[0].attrs["sharding.shard"].AsString())
+		require.True(t, spans[0].attrs["sharding.cache_hit"].AsBool())
+		require.Empty(t, recorder.byName("sharding.lock"))
+	})
+
+	t.Run("跨分表查询", func(t *testing.T) {
+		params, err := sharding.Params(sharding.ParamsBuilder().
+			Primary("user_logs").
+			Start(time.Date(2025, 8, 19, 17, 0, 0, 0, time.Local)).
+			End(time.Date(2025, 8, 22, 0, 0, 0, 0, time.Local)).
+			Type(sharding.Day))
+		require.NoError(t, err)
+		require.Len(t, params, 3)
+
+		boom := errors.New("boom")
+		err = sharding.ForEachShard(ctx, sharding.FanOutBuilder().Concurrency(2).TracerProvider(recorder), params,
+			func(ctx context.Context, param *sharding.ParamsResult) error {
+				require.Equal(t, param.TableName, trace.SpanFromContext(ctx).(*recordedSpan).attrs["sharding.shard"].AsString())
+				if param.TableName == "user_logs_20250820" {
+					return boom
+				}
+				return nil
+			})
+		require.ErrorIs(t, err, boom)
+		require.ErrorContains(t, err, "user_logs_20250820")
+
+		root := recorder.byName("sharding.ForEachShard")
+		require.Len(t, root, 1)
+		require.Equal(t, int64(3), root[0].attrs["sharding.shards"].AsInt64())
+		require.Equal(t, codes.Error, root[0].status)
+		queries := recorder.byName("sharding.query")
+		require.Len(t, queries, 3)
+		for _, span := range queries {
+			require.Same(t, root[0], span.parent)
+			if span.attrs["sharding.shard"].AsString() == "user_logs_20250820" {
+				require.Equal(t, codes.Error, span.status)
+			} else {
+				require.Equal(t, codes.Unset, span.status)
+			}
+		}
+	})
+
+	t.Run("跨分表查询取消", func(t *testing.T) {
+		params, err := sharding.Params(sharding.ParamsBuilder().
+			Primary("user_logs").
+			Start(time.Date(2025, 8, 19, 17, 0, 0, 0, time.Local)).
+			End(time.Date(2025, 8, 22, 0, 0, 0, 0, time.Local)).
+			Type(sharding.Day))
+		require.NoError(t, err)
+
+		// 第一张分表执行时取消，剩余分表不再调度；builder 为 nil 时按顺序执行
+		cancelCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var tables []string
+		err = sharding.ForEachShard(cancelCtx, nil, params, func(ctx context.Context, param *sharding.ParamsResult) error {
+			tables = append(tables, param.TableName)
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		require.ErrorContains(t, err, "2 张分表未执行")
+		require.Equal(t, []string{"user_logs_20250819"}, tables)
+
+		err = sharding.ForEachShard(ctx, nil, params, func(ctx context.Context, param *sharding.ParamsResult) error {
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
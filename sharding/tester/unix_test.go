@@ -0,0 +1,100 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// unixLog 时间列为毫秒时间戳
+type unixLog struct {
+	ID        int64 `db:"id,pk,auto"`
+	CreatedAt int64 `db:"created_at" shard:"time"`
+}
+
+// TestUnix 测试时间列为 unix 时间戳时的换算、查询条件和分表名
+func TestUnix(t *testing.T) {
+	ctx := context.Background()
+	shanghai := time.FixedZone("CST", 8*3600)
+	tm := time.Date(2025, 9, 1, 0, 30, 0, 0, shanghai)
+
+	t.Run("单位换算", func(t *testing.T) {
+		require.Equal(t, tm.Unix(), sharding.UnixSeconds.Value(tm))
+		require.Equal(t, tm.UnixMilli(), sharding.UnixMillis.Value(tm))
+		require.Equal(t, tm.UnixMicro(), sharding.UnixMicros.Value(tm))
+		require.True(t, tm.Equal(sharding.UnixMillis.Time(tm.UnixMilli(), shanghai)))
+		require.Equal(t, shanghai, sharding.UnixMillis.Time(tm.UnixMilli(), shanghai).Location())
+		require.Equal(t, time.Local, sharding.UnixSeconds.Time(tm.Unix(), nil).Location())
+		require.Equal(t, "millis", sharding.UnixMillis.String())
+	})
+
+	t.Run("Params 输出时间戳", func(t *testing.T) {
+		start := time.Date(2025, 8, 31, 12, 0, 0, 0, shanghai)
+		params, err := sharding.Params(sharding.ParamsBuilder().
+			Primary("logs").
+			UnixRange(start.UnixMilli(), tm.UnixMilli(), sharding.UnixMillis, shanghai).
+			Type(sharding.Day))
+		require.NoError(t, err)
+		require.Len(t, params, 2)
+		require.Equal(t, "logs_20250831", params[0].TableName)
+		require.Equal(t, "logs_20250901", params[1].TableName)
+		require.Equal(t, sharding.UnixMillis, params[0].Unit)
+		require.Equal(t, start.UnixMilli(), params[0].StartUnix)
+		require.Equal(t, time.Date(2025, 9, 1, 0, 0, 0, 0, shanghai).UnixMilli(), params[0].EndUnix)
+
+		where, args := params[1].Condition("created_at")
+		require.Equal(t, "`created_at` >= ? AND `created_at` < ?", where)
+		require.Equal(t, []any{params[1].StartUnix, tm.UnixMilli()}, args)
+	})
+
+	t.Run("不足一个单位的边界", func(t *testing.T) {
+		start := time.Date(2025, 8, 21, 10, 0, 0, 500_000_000, time.UTC)
+		end := time.Date(2025, 8, 21, 11, 0, 0, 500_000_000, time.UTC)
+		params, err := sharding.Params(sharding.ParamsBuilder().Primary("logs").Start(start).End(end).Type(sharding.Day).Unix(sharding.UnixSeconds))
+		require.NoError(t, err)
+		// 秒级列：>= 10:00:01 且 < 11:00:01，与按时间比较的结果一致
+		require.Equal(t, start.Unix()+1, params[0].StartUnix)
+		require.Equal(t, end.Unix()+1, params[0].EndUnix)
+
+		params, err = sharding.Params(sharding.ParamsBuilder().Primary("logs").Start(start).End(end).IsEndClose(true).Type(sharding.Day).Unix(sharding.UnixSeconds))
+		require.NoError(t, err)
+		require.Equal(t, end.Unix(), params[0].EndUnix)
+		where, _ := params[0].Condition("created_at")
+		require.Equal(t, "`created_at` >= ? AND `created_at` <= ?", where)
+
+		// 未设置 Unix 时参数为 time.Time
+		params, err = sharding.Params(sharding.ParamsBuilder().Primary("logs").Start(start).End(end).Type(sharding.Day))
+		require.NoError(t, err)
+		_, args := params[0].Condition("created_at")
+		require.Equal(t, []any{start, end}, args)
+		require.Zero(t, params[0].StartUnix)
+	})
+
+	t.Run("New 按时间戳和时区计算分表", func(t *testing.T) {
+		c := sharding.NewMemoryCache(0)
+		c.Store(ctx, sharding.CacheKey("test", "user_logs_20250901"))
+		// UTC 8 月 31 日 16:30，上海时间已是 9 月 1 日
+		table, err := sharding.New(offlineBuilder(t).ThisUnix(tm.UnixMilli(), sharding.UnixMillis, shanghai).Cache(c)).GetTableName()
+		require.NoError(t, err)
+		require.Equal(t, "user_logs_20250901", table)
+	})
+
+	t.Run("参数校验", func(t *testing.T) {
+		_, err := sharding.Params(sharding.ParamsBuilder().Primary("logs").Start(tm).End(tm).Type(sharding.Day).Unix(sharding.UnixUnit(9)))
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Unix"})
+		_, err = sharding.New(offlineBuilder(t).Unix(sharding.UnixUnit(9), nil)).GetTableName()
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Unix"})
+
+		// 时间戳字段需要设置 Unix，time.Time 字段不能设置 Unix
+		_, err = sharding.NewRepository[unixLog](sharding.RepositoryBuilder().Table(offlineBuilder(t)))
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Unix"})
+		_, err = sharding.NewRepository[repoLog](sharding.RepositoryBuilder().Table(offlineBuilder(t).Unix(sharding.UnixMillis, nil)))
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "Unix"})
+		repo, err := sharding.NewRepository[unixLog](sharding.RepositoryBuilder().Table(offlineBuilder(t).Unix(sharding.UnixMillis, shanghai)))
+		require.NoError(t, err)
+		err = repo.Insert(ctx, unixLog{})
+		require.ErrorIs(t, err, &sharding.ErrInvalidOption{Field: "ThisTime"})
+	})
+}
@@ -0,0 +1,34 @@
+package tester
+
+import (
+	"context"
+	"github.com/line-lee/toolkit/sharding"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// TestWarmup 测试启动时从已有分表预热缓存
+func TestWarmup(t *testing.T) {
+	mysqlClient := setupMysql(t)
+	ctx := context.Background()
+
+	for _, table := range []string{"warm_logs", "warm_logs_20250820", "warm_logs_20250821", "warm_logs_backup", "warm_orders_202508"} {
+		_, err := mysqlClient.Exec("CREATE TABLE `test`.`" + table + "` (`id` INT PRIMARY KEY)")
+		require.NoError(t, err)
+	}
+
+	c := sharding.NewMemoryCache(0)
+	result, err := sharding.Warmup(ctx, sharding.WarmupBuilder().
+		MysqlClient(mysqlClient).
+		DBName("test").
+		Primary("warm_logs", "warm_orders", "warm_users").
+		Cache(c))
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Total)
+	require.Equal(t, map[string]int{"warm_logs": 2, "warm_orders": 1, "warm_users": 0}, result.Primaries)
+	require.True(t, c.Exists(ctx, sharding.CacheKey("test", "warm_logs_20250821")))
+	require.False(t, c.Exists(ctx, sharding.CacheKey("test", "warm_logs_backup")))
+
+	_, err = sharding.Warmup(ctx, sharding.WarmupBuilder().MysqlClient(mysqlClient).DBName("test"))
+	require.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package sharding
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tier 分层分表中的一层，近期数据使用细粒度分表，过期后由 Compact 合并到下一层的粗粒度分表，
+// 例如最近 7 天按小时、90 天内按天、更早按月：
+//
+//	[]Tier{{Type: Hour, Age: 7 * 24 * time.Hour}, {Type: Day, Age: 90 * 24 * time.Hour}, {Type: Month}}
+type Tier struct {
+	// 分表类型，从细到粗
+	Type Type
+	// 数据在该层保留的时长，超过后合并到下一层，最后一层不需要设置
+	Age time.Duration
+}
+
+// segment 时间范围中使用同一分表类型的一段，左闭右开，最后一段按 isEndClose
+type segment struct {
+	start      time.Time
+	end        time.Time
+	isEndClose bool
+	t          Type
+	// 分表名前缀，为空时使用 Primary
+	primary string
+}
+
+// validateTiers 校验分层：至少两层，分表类型从细到粗，保留时长递增
+func validateTiers(tiers []Tier) error {
+	if len(tiers) < 2 {
+		return invalidOption("Tiers", "sharding，option Tiers 至少需要两层")
+	}
+	for i, tier := range tiers {
+		if tier.Type.layout() == "" {
+			return fmt.Errorf("sharding，第 %d 层 type %d：%w", i, tier.Type, ErrUnknownType)
+		}
+		if i == 0 {
+			continue
+		}
+		if tier.Type <= tiers[i-1].Type {
+			return invalidOption("Tiers", fmt.Sprintf("sharding，option Tiers 分表类型需要从细到粗，第 %d 层 %s 不比 %s 粗", i, tier.Type, tiers[i-1].Type))
+		}
+		if tiers[i-1].Age <= 0 || (i < len(tiers)-1 && tier.Age <= tiers[i-1].Age) {
+			return invalidOption("Tiers", fmt.Sprintf("sharding，option Tiers 保留时长需要递增，第 %d 层 %s", i-1, tiers[i-1].Age))
+		}
+	}
+	return nil
+}
+
+// tierBoundaries 相邻两层的分界时间，boundaries[i] 之后（包含）为第 i 层，之前为第 i+1 层，
+// 分界对齐到粗一层的分表开始时间：粗一层的分表整体早于 now - Age 后才会被合并
+func tierBoundaries(tiers []Tier, now time.Time) []time.Time {
+	var boundaries = make([]time.Time, len(tiers)-1)
+	for i := range boundaries {
+		boundaries[i], _ = tiers[i+1].Type.Bucket(now.Add(-tiers[i].Age))
+		if i > 0 && boundaries[i].After(boundaries[i-1]) {
+			boundaries[i] = boundaries[i-1]
+		}
+	}
+	return boundaries
+}
+
+// tierSegments 按分界时间把查询范围切成使用不同分表类型的几段
+func tierSegments(tiers []Tier, now, start, end time.Time, isEndClose bool) []*segment {
+	var boundaries = tierBoundaries(tiers, now)
+	// 按时间顺序，从最粗的一层开始
+	var levels = make([]*segment, len(tiers))
+	var cuts = make([]time.Time, len(boundaries))
+	for i, tier := range tiers {
+		levels[len(tiers)-1-i] = &segment{t: tier.Type}
+	}
+	for i, boundary := range boundaries {
+		cuts[len(boundaries)-1-i] = boundary
+	}
+	return cutSegments(levels, cuts, start, end, isEndClose)
+}
+
+// cutSegments 按时间顺序的分界 cuts 把查询范围切段，第 j 段使用 levels[j] 的分表类型，范围为 [cuts[j-1], cuts[j])，
+// 结果按时间顺序，只有最后一段按 isEndClose
+func cutSegments(levels []*segment, cuts []time.Time, start, end time.Time, isEndClose bool) []*segment {
+	var segments = make([]*segment, 0, len(levels))
+	for j, level := range levels {
+		var from, to = start, end
+		if j > 0 && from.Before(cuts[j-1]) {
+			from = cuts[j-1]
+		}
+		// end 所在的段为最后一段
+		var last = j == len(levels)-1 || end.Before(cuts[j])
+		if !last {
+			to = cuts[j]
+		}
+		if from.Before(to) || (last && from.Equal(to) && (isEndClose || start.Equal(end))) {
+			segments = append(segments, &segment{start: from, end: to, isEndClose: last && isEndClose, t: level.t, primary: level.primary})
+		}
+		if last {
+			break
+		}
+	}
+	return segments
+}
@@ -0,0 +1,43 @@
+package sharding
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName 分表 span 的 instrumentation 名
+const tracerName = "github.com/line-lee/toolkit/sharding"
+
+// span 属性名
+const (
+	attrDB       = attribute.Key("sharding.db")
+	attrPrimary  = attribute.Key("sharding.primary")
+	attrShard    = attribute.Key("sharding.shard")
+	attrCacheHit = attribute.Key("sharding.cache_hit")
+	attrAttempts = attribute.Key("sharding.lock_attempts")
+	attrWaitMs   = attribute.Key("sharding.lock_wait_ms")
+	attrShards   = attribute.Key("sharding.shards")
+	attrStart    = attribute.Key("sharding.start")
+	attrEnd      = attribute.Key("sharding.end")
+	attrSQL      = attribute.Key("db.statement")
+)
+
+// startSpan 开始 span，未设置 TracerProvider 时不产生 span
+func startSpan(ctx context.Context, tp trace.TracerProvider, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan 结束 span，err 不为空时记录错误
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,108 @@
+package sharding
+
+import (
+	"fmt"
+	"time"
+)
+
+// UnixUnit 整数时间列的单位，时间列为 BIGINT 存储的 unix 时间戳时使用
+type UnixUnit int
+
+const (
+	UnixSeconds UnixUnit = 1 // 秒
+	UnixMillis  UnixUnit = 2 // 毫秒
+	UnixMicros  UnixUnit = 3 // 微秒
+)
+
+// duration 单位时长，未识别的单位返回 0
+func (u UnixUnit) duration() time.Duration {
+	switch u {
+	case UnixSeconds:
+		return time.Second
+	case UnixMillis:
+		return time.Millisecond
+	case UnixMicros:
+		return time.Microsecond
+	default:
+		return 0
+	}
+}
+
+// Time 时间戳 value 对应的时间，loc 为 nil 时使用 time.Local，分表名按该时区计算
+func (u UnixUnit) Time(value int64, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.Local
+	}
+	switch u {
+	case UnixSeconds:
+		return time.Unix(value, 0).In(loc)
+	case UnixMillis:
+		return time.UnixMilli(value).In(loc)
+	case UnixMicros:
+		return time.UnixMicro(value).In(loc)
+	default:
+		return time.Time{}
+	}
+}
+
+// Value 时间 tm 的时间戳，不足一个单位的部分舍去
+func (u UnixUnit) Value(tm time.Time) int64 {
+	switch u {
+	case UnixSeconds:
+		return tm.Unix()
+	case UnixMillis:
+		return tm.UnixMilli()
+	case UnixMicros:
+		return tm.UnixMicro()
+	default:
+		return 0
+	}
+}
+
+// ceil 时间 tm 的时间戳，不足一个单位的部分进一，用于 >= start、< end 的边界：
+// 列值只能精确到单位，col >= ceil(start) 等价于 col >= start，col < ceil(end) 等价于 col < end
+func (u UnixUnit) ceil(tm time.Time) int64 {
+	var value = u.Value(tm)
+	if u.Time(value, tm.Location()).Before(tm) {
+		value++
+	}
+	return value
+}
+
+// String 单位名称：seconds、millis、micros
+func (u UnixUnit) String() string {
+	switch u {
+	case UnixSeconds:
+		return "seconds"
+	case UnixMillis:
+		return "millis"
+	case UnixMicros:
+		return "micros"
+	default:
+		return fmt.Sprintf("UnixUnit(%d)", int(u))
+	}
+}
+
+// validateUnit 校验时间列单位，0 表示 DATETIME 列
+func validateUnit(u UnixUnit) error {
+	if u != 0 && u.duration() == 0 {
+		return invalidOption("Unix", fmt.Sprintf("sharding，时间列单位 %d 不识别", int(u)))
+	}
+	return nil
+}
+
+// rangeCondition 时间列 column 在 [start, end) 或 [start, end] 内的条件，unit 为 0 时参数为 time.Time，否则为时间戳
+func rangeCondition(column string, unit UnixUnit, start, end time.Time, isEndClose bool) (string, []any) {
+	var op = "<"
+	if isEndClose {
+		op = "<="
+	}
+	var where = fmt.Sprintf("%s >= ? AND %s %s ?", quote(column), quote(column), op)
+	if unit == 0 {
+		return where, []any{start, end}
+	}
+	if isEndClose {
+		return where, []any{unit.ceil(start), unit.Value(end)}
+	}
+	return where, []any{unit.ceil(start), unit.ceil(end)}
+}
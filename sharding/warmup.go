@@ -0,0 +1,134 @@
+package sharding
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/line-lee/toolkit/beankit"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WarmupResult 缓存预热结果
+type WarmupResult struct {
+	// 写入缓存的分表总数
+	Total int
+	// 每个基础表写入缓存的分表数
+	Primaries map[string]int
+}
+
+// Warmup 启动时用一次 information_schema 查询列出所有基础表的已有分表，写入存在性缓存，
+// 避免发版后每张分表首次写入都要加锁、SHOW CREATE TABLE
+func Warmup(ctx context.Context, builder *WarmupOptionsBuilder) (*WarmupResult, error) {
+	option := new(WarmupOption)
+	for _, opf := range builder.funcs {
+		opf(option)
+	}
+	if option.mysqlClient == nil {
+		return nil, invalidOption("MysqlClient", "sharding.Warmup，option MysqlClient 必填")
+	}
+	if beankit.IsStringBlank(option.db) {
+		return nil, invalidOption("DBName", "sharding.Warmup，option DBName 必填")
+	}
+	if beankit.IsSliceEmpty(option.primaries) {
+		return nil, invalidOption("Primary", "sharding.Warmup，option Primary 必填")
+	}
+	if option.cache == nil {
+		option.cache = DefaultCache()
+	}
+	var query = "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND (" +
+		strings.TrimSuffix(strings.Repeat("TABLE_NAME LIKE ? OR ", len(option.primaries)), " OR ") + ")"
+	var args = []any{option.db}
+	for _, primary := range option.primaries {
+		args = append(args, likePrefix(primary+"_"))
+	}
+	var result = &WarmupResult{Primaries: make(map[string]int)}
+	for _, primary := range option.primaries {
+		result.Primaries[primary] = 0
+	}
+	err := queryEach(ctx, option.mysqlClient, query, args, func(rows *sql.Rows) error {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return err
+		}
+		for _, primary := range option.primaries {
+			if _, ok := ParseShard(primary, table, time.Local); ok {
+				option.cache.Store(ctx, CacheKey(option.db, table))
+				result.Primaries[primary]++
+				result.Total++
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sharding.Warmup，分表查询失败：%w", err)
+	}
+	return result, nil
+}
+
+// WarmupOption 缓存预热参数，由 WarmupBuilder 传入
+type WarmupOption struct {
+	// 数据库连接
+	mysqlClient *sql.DB
+	// 库名
+	db string
+	// 基础表名
+	primaries []string
+	// 写入的缓存，默认 DefaultCache()
+	cache Cache
+}
+
+type WarmupOptionsBuilder struct {
+	funcs []WarmupOptionFunc
+}
+
+func WarmupBuilder() *WarmupOptionsBuilder {
+	return &WarmupOptionsBuilder{}
+}
+
+type WarmupOptionFunc func(*WarmupOption)
+
+func (wb *WarmupOptionsBuilder) MysqlClient(mysqlClient *sql.DB) *WarmupOptionsBuilder {
+	wb.funcs = append(wb.funcs, func(opt *WarmupOption) {
+		opt.mysqlClient = mysqlClient
+	})
+	return wb
+}
+
+func (wb *WarmupOptionsBuilder) DBName(dbName string) *WarmupOptionsBuilder {
+	wb.funcs = append(wb.funcs, func(opt *WarmupOption) {
+		opt.db = dbName
+	})
+	return wb
+}
+
+// Primary 追加需要预热的基础表
+func (wb *WarmupOptionsBuilder) Primary(primaries ...string) *WarmupOptionsBuilder {
+	wb.funcs = append(wb.funcs, func(opt *WarmupOption) {
+		opt.primaries = append(opt.primaries, primaries...)
+	})
+	return wb
+}
+
+// Schedule 分表粒度调整计划，同时预热调整后新分表名前缀（Cutover.Primary）的分表；
+// 各种粒度的分表都会写入缓存，分层分表不需要额外设置
+func (wb *WarmupOptionsBuilder) Schedule(schedule ...Cutover) *WarmupOptionsBuilder {
+	wb.funcs = append(wb.funcs, func(opt *WarmupOption) {
+		for _, cutover := range schedule {
+			if cutover.Primary != "" && !slices.Contains(opt.primaries, cutover.Primary) {
+				opt.primaries = append(opt.primaries, cutover.Primary)
+			}
+		}
+	})
+	return wb
+}
+
+// Cache 写入的缓存，需要与 TableBuilder 使用的缓存一致，默认 DefaultCache()
+func (wb *WarmupOptionsBuilder) Cache(c Cache) *WarmupOptionsBuilder {
+	wb.funcs = append(wb.funcs, func(opt *WarmupOption) {
+		opt.cache = c
+	})
+	return wb
+}